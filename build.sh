@@ -5,6 +5,10 @@ src=`find . -type f | egrep '\.go$'`
 gofmt -s -w $src
 go vet .
 go vet ./equal
+go vet ./golden
+go vet ./golden/internal/ownflag
+go vet ./equalfiletest
+go vet ./internal/diff
 go tool fix $src
 go install .
 go install ./equal
@@ -15,6 +19,9 @@ simple() {
     # gosimple cant handle source files from multiple packages
     $s *.go
     $s equal/*.go
+    $s golden/*.go
+    $s equalfiletest/*.go
+    $s internal/diff/*.go
 }
 [ -x "$s" ] && simple
 
@@ -24,7 +31,11 @@ lint() {
     # golint cant handle source files from multiple packages
     $l *.go
     $l equal/*.go
+    $l golden/*.go
+    $l equalfiletest/*.go
+    $l internal/diff/*.go
 }
 [ -x "$l" ] && lint
 
 go test -v
+go test -v ./equal ./golden ./golden/internal/ownflag ./equalfiletest ./internal/diff
@@ -0,0 +1,234 @@
+package equalfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ChecksumEntry is one line from a checksum manifest as produced by GNU
+// coreutils tools like sha256sum and md5sum.
+type ChecksumEntry struct {
+	Path   string
+	Sum    []byte
+	Binary bool   // '*' marker: file was read in binary mode
+	Algo   string // algorithm from a BSD-style tagged line, e.g. "SHA256"; empty otherwise
+}
+
+// ChecksumLineError describes a manifest line skipped by ParseChecksums.
+type ChecksumLineError struct {
+	Line int // starting at 1
+	Err  error
+}
+
+func (e ChecksumLineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// ChecksumFormatError is returned by ParseChecksums, along with the entries
+// from the well-formed lines, when some lines were improperly formatted.
+type ChecksumFormatError struct {
+	Lines []ChecksumLineError
+}
+
+func (e *ChecksumFormatError) Error() string {
+	if len(e.Lines) == 1 {
+		return e.Lines[0].Error()
+	}
+	return fmt.Sprintf("%d improperly formatted lines, first %v", len(e.Lines), e.Lines[0])
+}
+
+// ParseChecksums reads a checksum manifest. It accepts the GNU format
+// "<hex>  <path>" (text mode) and "<hex> *<path>" (binary mode), as well
+// as BSD-style tagged lines "<ALGO> (<path>) = <hex>". Escaped file names
+// (lines starting with a backslash) are decoded. Empty lines and lines
+// starting with '#' are ignored.
+//
+// Like sha256sum -c, improperly formatted lines are skipped rather than
+// ending the parse: all valid entries are returned, together with a
+// *ChecksumFormatError listing the skipped lines.
+func ParseChecksums(r io.Reader) ([]ChecksumEntry, error) {
+	var entries []ChecksumEntry
+	var malformed []ChecksumLineError
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		e, err := parseChecksumLine(line)
+		if err != nil {
+			malformed = append(malformed, ChecksumLineError{Line: lineNum, Err: err})
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return entries, err
+	}
+	if len(malformed) > 0 {
+		return entries, &ChecksumFormatError{Lines: malformed}
+	}
+	return entries, nil
+}
+
+func parseChecksumLine(line string) (ChecksumEntry, error) {
+	var e ChecksumEntry
+
+	escaped := line[0] == '\\'
+	if escaped {
+		line = line[1:]
+	}
+
+	var sum, path string
+
+	if i := strings.Index(line, " ("); i > 0 && !strings.Contains(line[:i], " ") {
+		// BSD tagged: ALGO (path) = hex
+		j := strings.LastIndex(line, ") = ")
+		if j < i {
+			return e, fmt.Errorf("improperly formatted tagged checksum line")
+		}
+		e.Algo = line[:i]
+		path = line[i+2 : j]
+		sum = line[j+4:]
+	} else {
+		// GNU: hex, space, marker (space or '*'), path
+		i := strings.IndexByte(line, ' ')
+		if i < 1 || i+2 > len(line) {
+			return e, fmt.Errorf("improperly formatted checksum line")
+		}
+		sum = line[:i]
+		switch line[i+1] {
+		case ' ':
+		case '*':
+			e.Binary = true
+		default:
+			return e, fmt.Errorf("improperly formatted checksum line")
+		}
+		path = line[i+2:]
+	}
+
+	if path == "" {
+		return e, fmt.Errorf("missing file name")
+	}
+
+	if escaped {
+		var errUnescape error
+		path, errUnescape = unescapeChecksumPath(path)
+		if errUnescape != nil {
+			return e, errUnescape
+		}
+	}
+
+	decoded, errHex := hex.DecodeString(sum)
+	if errHex != nil || len(decoded) == 0 {
+		return e, fmt.Errorf("bad checksum [%s]", sum)
+	}
+
+	e.Path = path
+	e.Sum = decoded
+
+	return e, nil
+}
+
+func unescapeChecksumPath(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", fmt.Errorf("bad escape at end of file name")
+		}
+		switch s[i] {
+		case '\\':
+			b.WriteByte('\\')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			return "", fmt.Errorf("bad escape \\%c in file name", s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+var checksumPathEscaper = strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\r", "\\r")
+
+// WriteChecksums writes entries in the format read by ParseChecksums.
+// Entries with Algo set are written as BSD-style tagged lines, others in
+// the GNU format. File names with special characters are escaped.
+func WriteChecksums(w io.Writer, entries []ChecksumEntry) error {
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		path := e.Path
+		var prefix string
+		if strings.ContainsAny(path, "\\\n\r") {
+			prefix = "\\"
+			path = checksumPathEscaper.Replace(path)
+		}
+		var err error
+		switch {
+		case e.Algo != "":
+			_, err = fmt.Fprintf(bw, "%s%s (%s) = %x\n", prefix, e.Algo, path, e.Sum)
+		case e.Binary:
+			_, err = fmt.Fprintf(bw, "%s%x *%s\n", prefix, e.Sum, path)
+		default:
+			_, err = fmt.Fprintf(bw, "%s%x  %s\n", prefix, e.Sum, path)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// HashFile returns the hash of the whole file, using the multiple mode
+// hash cache. It fails if Cmp was not created by NewMultiple.
+func (c *Cmp) HashFile(path string) ([]byte, error) {
+	if !c.multipleMode() {
+		return nil, fmt.Errorf("HashFile requires multiple mode")
+	}
+
+	if c.Opt.MaxSize < 0 {
+		return nil, fmt.Errorf("negative MaxSize")
+	}
+
+	info, statErr := c.storage().Stat(path)
+	if statErr != nil {
+		return nil, statErr
+	}
+
+	maxSize := c.Opt.MaxSize
+	if maxSize == 0 {
+		maxSize = info.Size()
+		if maxSize < 1 || !info.Mode().IsRegular() {
+			maxSize = defaultMaxSize
+		}
+	}
+
+	return c.getHash(path, maxSize, PhaseHash1)
+}
+
+// VerifyChecksum reports whether file path has the expected hash sum,
+// computed with the multiple mode hash.
+func (c *Cmp) VerifyChecksum(path string, sum []byte) (bool, error) {
+	h, err := c.HashFile(path)
+	if err != nil {
+		return false, err
+	}
+	match := bytes.Equal(h, sum)
+	c.debugf("VerifyChecksum(%s): match=%v\n", path, match)
+	return match, nil
+}
@@ -0,0 +1,115 @@
+package equalfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestParseChecksums(t *testing.T) {
+	manifest := `# comment
+e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  empty.txt
+e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855 *bin/empty (1).bin
+
+SHA256 (tagged name.txt) = e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855
+\e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  back\\slash\nnewline
+`
+	entries, err := ParseChecksums(strings.NewReader(manifest))
+	if err != nil {
+		t.Fatalf("ParseChecksums: unexpected error: %v", err)
+	}
+
+	want := []ChecksumEntry{
+		{Path: "empty.txt"},
+		{Path: "bin/empty (1).bin", Binary: true},
+		{Path: "tagged name.txt", Algo: "SHA256"},
+		{Path: "back\\slash\nnewline"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("ParseChecksums: got %d entries expected %d", len(entries), len(want))
+	}
+	emptySum := sha256.Sum256(nil)
+	for i, e := range entries {
+		w := want[i]
+		if e.Path != w.Path || e.Binary != w.Binary || e.Algo != w.Algo {
+			t.Errorf("entry %d: got %+v expected %+v", i, e, w)
+		}
+		if !bytes.Equal(e.Sum, emptySum[:]) {
+			t.Errorf("entry %d: got sum %x", i, e.Sum)
+		}
+	}
+
+	// Writing and parsing again must round trip.
+	var buf bytes.Buffer
+	if err := WriteChecksums(&buf, entries); err != nil {
+		t.Fatalf("WriteChecksums: %v", err)
+	}
+	again, err := ParseChecksums(&buf)
+	if err != nil {
+		t.Fatalf("ParseChecksums round trip: %v", err)
+	}
+	for i := range entries {
+		if again[i].Path != entries[i].Path || again[i].Binary != entries[i].Binary || again[i].Algo != entries[i].Algo || !bytes.Equal(again[i].Sum, entries[i].Sum) {
+			t.Errorf("round trip entry %d: got %+v expected %+v", i, again[i], entries[i])
+		}
+	}
+}
+
+func TestParseChecksumsMalformed(t *testing.T) {
+	for _, line := range []string{
+		"nothex  file",
+		"e3b0c442",
+		"e3b0c442 -file",
+		"e3b0c442  ",
+		"SHA256 (file = e3b0c442",
+	} {
+		if _, err := ParseChecksums(strings.NewReader(line)); err == nil {
+			t.Errorf("ParseChecksums(%q): missing expected error", line)
+		}
+	}
+
+	// A bad line must not hide the entries around it.
+	manifest := `e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  first
+garbage
+e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855 -bad marker
+e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  second
+`
+	entries, err := ParseChecksums(strings.NewReader(manifest))
+	formatErr, isFormatErr := err.(*ChecksumFormatError)
+	if !isFormatErr {
+		t.Fatalf("ParseChecksums: got error %v expected *ChecksumFormatError", err)
+	}
+	if len(formatErr.Lines) != 2 || formatErr.Lines[0].Line != 2 || formatErr.Lines[1].Line != 3 {
+		t.Errorf("ParseChecksums: got malformed lines %v expected lines 2 and 3", formatErr.Lines)
+	}
+	if len(entries) != 2 || entries[0].Path != "first" || entries[1].Path != "second" {
+		t.Errorf("ParseChecksums: got entries %+v expected first and second", entries)
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	pat := "equalfiles_test_checksum"
+	contents := [][]byte{[]byte("abc")}
+	tmpFiles := makeTmpFiles(t, pat, contents)
+	defer cleanupTmpFiles(tmpFiles)
+
+	sum := sha256.Sum256(contents[0])
+
+	if _, err := New(nil, Options{}).VerifyChecksum(tmpFiles[0].Name(), sum[:]); err == nil {
+		t.Errorf("VerifyChecksum should require multiple mode")
+	}
+
+	c := NewMultiple(nil, Options{}, sha256.New(), false)
+	if ok, err := c.VerifyChecksum(tmpFiles[0].Name(), sum[:]); !ok || err != nil {
+		t.Errorf("VerifyChecksum: got %v %v expected match", ok, err)
+	}
+	bad, _ := hex.DecodeString("e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+	if ok, err := c.VerifyChecksum(tmpFiles[0].Name(), bad); ok || err != nil {
+		t.Errorf("VerifyChecksum: got %v %v expected mismatch", ok, err)
+	}
+	if _, err := c.VerifyChecksum(tmpFiles[0].Name()+"_missing", sum[:]); err == nil {
+		t.Errorf("VerifyChecksum: missing expected error for missing file")
+	}
+}
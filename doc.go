@@ -26,5 +26,10 @@ You must provide the hashing function.
         cmp := equalfile.NewMultiple(nil, equalfile.Options{}, sha256.New(), true) // enable multiple mode
         equal, err := cmp.CompareFile("file1", "file2")
 
+NewFastHash provides a fast non-cryptographic hash for multiple mode.
+Since collisions are possible, matches are always confirmed by comparing bytes.
+
+        cmp := equalfile.NewMultiple(nil, equalfile.Options{}, equalfile.NewFastHash(), true)
+
 */
 package equalfile
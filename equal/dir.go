@@ -0,0 +1,157 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/udhos/equalfile"
+)
+
+// compareTree compares path1 and path2 like "diff -rq": directories are
+// walked recursively and every difference found is reported.
+func compareTree(cmp *equalfile.Cmp, out *output, hashAlgo, path1, path2 string) bool {
+	return compareEntry(cmp, out, hashAlgo, path1, path2, nil)
+}
+
+// dirChain holds the pairs of directories being walked, innermost first,
+// so that symbolic link loops can be detected.
+type dirChain struct {
+	info1, info2 os.FileInfo
+	parent       *dirChain
+}
+
+// loop reports whether either directory is already being walked on the
+// same side, like diff does.
+func (d *dirChain) loop(info1, info2 os.FileInfo) bool {
+	for ; d != nil; d = d.parent {
+		if os.SameFile(d.info1, info1) || os.SameFile(d.info2, info2) {
+			return true
+		}
+	}
+	return false
+}
+
+func compareEntry(cmp *equalfile.Cmp, out *output, hashAlgo, path1, path2 string, parents *dirChain) bool {
+	stat := os.Stat
+	if cmp.Opt.Symlinks != equalfile.SymlinkFollow {
+		stat = os.Lstat // links are compared as links
+	}
+
+	info1, err1 := stat(path1)
+	if err1 != nil {
+		return out.pair(pairRecord{Path1: path1, Path2: path2, Verdict: verdictError, Error: err1.Error()})
+	}
+	info2, err2 := stat(path2)
+	if err2 != nil {
+		return out.pair(pairRecord{Path1: path1, Path2: path2, Verdict: verdictError, Error: err2.Error()})
+	}
+
+	if cmp.Opt.Symlinks == equalfile.SymlinkTypeMismatch && isSymlink(info1) && isSymlink(info2) {
+		// both are links, so both are followed, as CompareFile does
+		if info1, err1 = os.Stat(path1); err1 != nil {
+			return out.pair(pairRecord{Path1: path1, Path2: path2, Verdict: verdictError, Error: err1.Error()})
+		}
+		if info2, err2 = os.Stat(path2); err2 != nil {
+			return out.pair(pairRecord{Path1: path1, Path2: path2, Verdict: verdictError, Error: err2.Error()})
+		}
+	}
+
+	switch {
+	case info1.IsDir() && info2.IsDir():
+		if parents.loop(info1, info2) {
+			return out.pair(pairRecord{Path1: path1, Path2: path2, Verdict: verdictError, Error: "recursive directory loop"})
+		}
+		return compareDirs(cmp, out, hashAlgo, path1, path2, &dirChain{info1: info1, info2: info2, parent: parents})
+	case fileType(info1) != fileType(info2):
+		return out.pair(pairRecord{
+			Path1:     path1,
+			Path2:     path2,
+			Verdict:   verdictDifferent,
+			Reason:    reasonTypeMismatch,
+			FileType1: fileType(info1),
+			FileType2: fileType(info2),
+		})
+	case !info1.Mode().IsRegular() && !(isSymlink(info1) && cmp.Opt.Symlinks == equalfile.SymlinkCompareTarget):
+		// like diff, fifos, sockets and devices are never read
+		return out.pair(pairRecord{
+			Path1:     path1,
+			Path2:     path2,
+			Verdict:   verdictDifferent,
+			Reason:    reasonSpecialFile,
+			FileType1: fileType(info1),
+			FileType2: fileType(info2),
+		})
+	}
+
+	equal, err := cmp.CompareFile(path1, path2)
+	return out.pair(compared(cmp.LastResult(), hashAlgo, path1, path2, equal, err))
+}
+
+func compareDirs(cmp *equalfile.Cmp, out *output, hashAlgo, dir1, dir2 string, parents *dirChain) bool {
+	list1, err1 := ioutil.ReadDir(dir1)
+	if err1 != nil {
+		return out.pair(pairRecord{Path1: dir1, Path2: dir2, Verdict: verdictError, Error: err1.Error()})
+	}
+	list2, err2 := ioutil.ReadDir(dir2)
+	if err2 != nil {
+		return out.pair(pairRecord{Path1: dir1, Path2: dir2, Verdict: verdictError, Error: err2.Error()})
+	}
+
+	match := true
+
+	// ReadDir returns entries sorted by name, so both lists can be merged.
+	i, j := 0, 0
+	for i < len(list1) || j < len(list2) {
+		switch {
+		case j >= len(list2) || (i < len(list1) && list1[i].Name() < list2[j].Name()):
+			name := list1[i].Name()
+			out.pair(pairRecord{Path1: filepath.Join(dir1, name), Path2: filepath.Join(dir2, name), Verdict: verdictDifferent, Reason: reasonOnlyIn1})
+			match = false
+			i++
+		case i >= len(list1) || list2[j].Name() < list1[i].Name():
+			name := list2[j].Name()
+			out.pair(pairRecord{Path1: filepath.Join(dir1, name), Path2: filepath.Join(dir2, name), Verdict: verdictDifferent, Reason: reasonOnlyIn2})
+			match = false
+			j++
+		default:
+			name := list1[i].Name()
+			if !compareEntry(cmp, out, hashAlgo, filepath.Join(dir1, name), filepath.Join(dir2, name), parents) {
+				match = false
+			}
+			i++
+			j++
+		}
+	}
+
+	return match
+}
+
+func isSymlink(info os.FileInfo) bool {
+	return info.Mode()&os.ModeSymlink != 0
+}
+
+// fileType names the file type the same way diff does.
+func fileType(info os.FileInfo) string {
+	mode := info.Mode()
+	switch {
+	case mode.IsRegular():
+		if info.Size() == 0 {
+			return "regular empty file"
+		}
+		return "regular file"
+	case mode.IsDir():
+		return "directory"
+	case isSymlink(info):
+		return "symbolic link"
+	case mode&os.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&os.ModeSocket != 0:
+		return "socket"
+	case mode&os.ModeCharDevice != 0:
+		return "character special file"
+	case mode&os.ModeDevice != 0:
+		return "block special file"
+	}
+	return "weird file"
+}
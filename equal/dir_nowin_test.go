@@ -0,0 +1,39 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/udhos/equalfile"
+)
+
+func TestCompareTreeSpecialFiles(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	writeTree(t, a, map[string]string{"f": "x"})
+	writeTree(t, b, map[string]string{"f": "x"})
+	for _, d := range []string{a, b} {
+		if err := syscall.Mkfifo(filepath.Join(d, "p"), 0644); err != nil {
+			t.Skipf("mkfifo: %v", err)
+		}
+	}
+
+	// would block forever reading the fifos
+	records, match := compareTreeRecords(t, equalfile.Options{}, a, b)
+	if match {
+		t.Errorf("expected trees to differ")
+	}
+	if rec := records["p"]; rec.Verdict != verdictDifferent || rec.Reason != reasonSpecialFile || rec.FileType2 != "fifo" {
+		t.Errorf("fifo: unexpected record %+v", rec)
+	}
+	if rec := records["f"]; rec.Verdict != verdictEqual {
+		t.Errorf("f: unexpected record %+v", rec)
+	}
+}
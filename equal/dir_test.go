@@ -0,0 +1,159 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/udhos/equalfile"
+)
+
+// writeTree creates files under dir, mapping slash separated paths to
+// contents.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "equal-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// compareTreeRecords runs compareTree and returns the records by path1,
+// relative to dir1.
+func compareTreeRecords(t *testing.T, opt equalfile.Options, dir1, dir2 string) (map[string]pairRecord, bool) {
+	t.Helper()
+	out := newOutput(&config{format: formatJSON, recursive: true})
+	match := compareTree(equalfile.New(nil, opt), out, "", dir1, dir2)
+	records := map[string]pairRecord{}
+	for _, rec := range out.records {
+		rel, err := filepath.Rel(dir1, rec.Path1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records[filepath.ToSlash(rel)] = rec
+	}
+	return records, match
+}
+
+func TestCompareDirsMerge(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+
+	writeTree(t, a, map[string]string{"both": "x", "differ": "1", "a-only": "", "sub/c": "c", "z": "z"})
+	writeTree(t, b, map[string]string{"both": "x", "differ": "2", "b-only": "", "sub/c": "c", "sub/d": "d"})
+
+	records, match := compareTreeRecords(t, equalfile.Options{}, a, b)
+	if match {
+		t.Errorf("expected trees to differ")
+	}
+
+	expected := map[string]struct{ verdict, reason string }{
+		"a-only": {verdictDifferent, reasonOnlyIn1},
+		"b-only": {verdictDifferent, reasonOnlyIn2},
+		"both":   {verdictEqual, equalfile.ReasonContentMatch},
+		"differ": {verdictDifferent, equalfile.ReasonContentMismatch},
+		"sub/c":  {verdictEqual, equalfile.ReasonContentMatch},
+		"sub/d":  {verdictDifferent, reasonOnlyIn2},
+		"z":      {verdictDifferent, reasonOnlyIn1},
+	}
+	if len(records) != len(expected) {
+		t.Errorf("expected %d records, got %v", len(expected), records)
+	}
+	for path, e := range expected {
+		rec, found := records[path]
+		if !found {
+			t.Errorf("%s: missing record", path)
+			continue
+		}
+		if rec.Verdict != e.verdict || rec.Reason != e.reason {
+			t.Errorf("%s: expected %s %q, got %s %q", path, e.verdict, e.reason, rec.Verdict, rec.Reason)
+		}
+	}
+}
+
+func TestCompareTreeLoop(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	writeTree(t, a, map[string]string{"d/f": "x"})
+	writeTree(t, b, map[string]string{"d/f": "x"})
+	for _, d := range []string{a, b} {
+		if err := os.Symlink("..", filepath.Join(d, "d", "up")); err != nil {
+			t.Skipf("symlink: %v", err)
+		}
+	}
+
+	records, match := compareTreeRecords(t, equalfile.Options{}, a, b)
+	if match {
+		t.Errorf("expected loop to be reported")
+	}
+	if rec := records["d/up"]; rec.Verdict != verdictError || rec.Error != "recursive directory loop" {
+		t.Errorf("d/up: unexpected record %+v", rec)
+	}
+	if len(records) != 2 {
+		t.Errorf("expected 2 records, got %v", records)
+	}
+}
+
+func TestCompareTreeSymlinkType(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	writeTree(t, dir, map[string]string{
+		"a/f": "x", "b/f": "x",
+		"t1/x": "1", "t2/x": "1", "t3/x": "2",
+	})
+	links := []struct{ target, path string }{
+		{"../t1", "a/l"}, {"../t2", "b/l"}, // links to matching directories
+		{"../t1", "a/m"}, {"../t3", "b/m"}, // links to differing directories
+		{"f", "a/n"}, {"f", "b/n"}, // links to files
+		{"../t1", "a/o"}, // link versus directory
+	}
+	for _, l := range links {
+		if err := os.Symlink(l.target, filepath.Join(dir, filepath.FromSlash(l.path))); err != nil {
+			t.Skipf("symlink: %v", err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(b, "o"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	records, match := compareTreeRecords(t, equalfile.Options{Symlinks: equalfile.SymlinkTypeMismatch}, a, b)
+	if match {
+		t.Errorf("expected trees to differ")
+	}
+	expected := map[string]struct{ verdict, reason string }{
+		"f":   {verdictEqual, equalfile.ReasonContentMatch},
+		"l/x": {verdictEqual, equalfile.ReasonContentMatch},
+		"m/x": {verdictDifferent, equalfile.ReasonContentMismatch},
+		"n":   {verdictEqual, equalfile.ReasonContentMatch},
+		"o":   {verdictDifferent, reasonTypeMismatch},
+	}
+	if len(records) != len(expected) {
+		t.Errorf("expected %d records, got %v", len(expected), records)
+	}
+	for path, e := range expected {
+		if rec := records[path]; rec.Verdict != e.verdict || rec.Reason != e.reason {
+			t.Errorf("%s: expected %s %q, got %+v", path, e.verdict, e.reason, rec)
+		}
+	}
+}
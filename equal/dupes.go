@@ -0,0 +1,325 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/udhos/equalfile"
+)
+
+type dupesConfig struct {
+	options        equalfile.Options
+	bufSize        int64
+	hashName       string
+	compareOnMatch bool
+	minSize        int64
+	empty          bool
+	hardLinks      bool
+	format         string
+	quiet          bool
+	link           string
+	keep           string
+	dryRun         bool
+	progress       bool
+}
+
+// dupeGroup holds paths with identical content.
+type dupeGroup struct {
+	Size        int64    `json:"size"`
+	Files       []string `json:"files"`
+	Reclaimable int64    `json:"reclaimable"`
+
+	// set when duplicates are replaced by links
+	Keep   string       `json:"keep,omitempty"`
+	Linked []linkAction `json:"linked,omitempty"`
+
+	infos []os.FileInfo
+}
+
+type dupesSummary struct {
+	Groups      int   `json:"groups"`
+	Files       int   `json:"files"`
+	Reclaimable int64 `json:"reclaimable"`
+}
+
+func dupesUsage(fs *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintf(os.Stderr, "usage: equal dupes [flags] root1 [...rootN]\n")
+		fs.PrintDefaults()
+	}
+}
+
+func parseDupesFlags(args []string) (*dupesConfig, []string) {
+	cfg := &dupesConfig{}
+
+	var bufSize, minSize, bwlimit string
+
+	fs := flag.NewFlagSet("dupes", flag.ExitOnError)
+	fs.Usage = dupesUsage(fs)
+	fs.StringVar(&cfg.hashName, "hash", defaultHash, "hash algorithm: "+hashNames())
+	fs.BoolVar(&cfg.compareOnMatch, "verify-hash", envBool("COMPARE_ON_MATCH"), "compare bytes when hashes match [COMPARE_ON_MATCH]")
+	fs.StringVar(&minSize, "min-size", "1", "ignore files smaller than this size (accepts suffixes like 64K, 1M)")
+	fs.BoolVar(&cfg.empty, "empty", false, "include empty files")
+	fs.BoolVar(&cfg.hardLinks, "hard-links", false, "report hard links to the same inode as duplicates")
+	fs.StringVar(&bufSize, "buf-size", os.Getenv("BUF_SIZE"), "read buffer size (accepts suffixes like 64K, 1M) [BUF_SIZE]")
+	fs.StringVar(&bwlimit, "bwlimit", "", "limit reading to this many bytes per second (accepts suffixes like 50M)")
+	fs.IntVar(&cfg.options.IOPSLimit, "iops-limit", 0, "limit reading to this many reads per second")
+	fs.BoolVar(&cfg.options.NoAtime, "noatime", false, "open files without updating access times, where permitted (Linux O_NOATIME)")
+	fs.BoolVar(&cfg.options.DropCache, "drop-cache", false, "keep compared files from filling the page cache (Linux posix_fadvise)")
+	fs.BoolVar(&cfg.progress, "progress", false, "show progress on stderr: a bar with throughput and ETA on a terminal, periodic lines otherwise")
+	fs.BoolVar(&cfg.options.Debug, "debug", envBool("DEBUG"), "enable debugging to stdout [DEBUG]")
+	fs.StringVar(&cfg.link, "link", "", "replace duplicates with links: hard or sym")
+	fs.StringVar(&cfg.keep, "keep", keepFirstPath, "file kept when linking: oldest, newest, first-path or path-priority (order of roots)")
+	fs.BoolVar(&cfg.dryRun, "dry-run", false, "with --link, show what would be replaced without changing anything")
+	fs.StringVar(&cfg.format, "format", formatText, "output format: text or json")
+	fs.BoolVar(&cfg.quiet, "quiet", false, "print nothing, report result only through exit status")
+	fs.Parse(args)
+
+	var errConv error
+	if cfg.minSize, errConv = parseSize(minSize); errConv != nil {
+		fmt.Fprintf(os.Stderr, "equal: bad min size [%s]: %v\n", minSize, errConv)
+		os.Exit(exitTrouble)
+	}
+	if bufSize != "" {
+		if cfg.bufSize, errConv = parseSize(bufSize); errConv != nil {
+			fmt.Fprintf(os.Stderr, "equal: bad buffer size [%s]: %v\n", bufSize, errConv)
+			os.Exit(exitTrouble)
+		}
+	}
+	if bwlimit != "" {
+		if cfg.options.BandwidthLimit, errConv = parseRate(bwlimit); errConv != nil {
+			fmt.Fprintf(os.Stderr, "equal: bad bandwidth limit [%s]: %v\n", bwlimit, errConv)
+			os.Exit(exitTrouble)
+		}
+	}
+	shareLimits(&cfg.options)
+	if _, _, errHash := newHash(cfg.hashName); errHash != nil {
+		fmt.Fprintf(os.Stderr, "equal: %v\n", errHash)
+		os.Exit(exitTrouble)
+	}
+	switch cfg.format {
+	case formatText, formatJSON:
+	default:
+		fmt.Fprintf(os.Stderr, "equal: bad format [%s]: expecting %s or %s\n", cfg.format, formatText, formatJSON)
+		os.Exit(exitTrouble)
+	}
+
+	switch cfg.link {
+	case "", linkHard, linkSym:
+	default:
+		fmt.Fprintf(os.Stderr, "equal: bad link [%s]: expecting %s or %s\n", cfg.link, linkHard, linkSym)
+		os.Exit(exitTrouble)
+	}
+	switch cfg.keep {
+	case keepOldest, keepNewest, keepFirstPath, keepPathPriority:
+	default:
+		fmt.Fprintf(os.Stderr, "equal: bad keep [%s]: expecting %s, %s, %s or %s\n", cfg.keep, keepOldest, keepNewest, keepFirstPath, keepPathPriority)
+		os.Exit(exitTrouble)
+	}
+
+	if cfg.quiet {
+		cfg.options.Debug = false
+		cfg.progress = false
+	}
+	if cfg.progress {
+		enableProgress(&cfg.options)
+	}
+
+	// empty files are always equal, so hashing would be pointless
+	if cfg.minSize < 1 && !cfg.empty {
+		cfg.minSize = 1
+	}
+
+	roots := fs.Args()
+	if len(roots) < 1 {
+		fs.Usage()
+		os.Exit(exitTrouble)
+	}
+
+	return cfg, roots
+}
+
+// dupes implements the "equal dupes" subcommand.
+func dupes(args []string) int {
+	cfg, roots := parseDupesFlags(args)
+
+	groups, trouble := findDupes(cfg, roots)
+
+	if cfg.link != "" && linkDupes(cfg, groups, roots) {
+		trouble = true
+	}
+
+	if !cfg.quiet {
+		printDupes(cfg, groups)
+	}
+
+	if trouble {
+		return exitTrouble
+	}
+	return exitEqual
+}
+
+// findDupes walks roots and returns groups of files with identical content.
+// Files are first bucketed by size, so only files with the same size are
+// ever hashed or compared.
+func findDupes(cfg *dupesConfig, roots []string) ([]*dupeGroup, bool) {
+	var trouble bool
+
+	seen := map[string]bool{}
+	bySize := map[int64][]*dupeGroup{} // each file starts as a singleton group
+	var sizes []int64
+
+	for _, root := range roots {
+		errWalk := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "equal: %v\n", err)
+				trouble = true
+				return nil
+			}
+			if !info.Mode().IsRegular() || info.Size() < cfg.minSize {
+				return nil
+			}
+			path = filepath.Clean(path)
+			if seen[path] {
+				return nil // overlapping roots
+			}
+			seen[path] = true
+			if _, found := bySize[info.Size()]; !found {
+				sizes = append(sizes, info.Size())
+			}
+			bySize[info.Size()] = append(bySize[info.Size()], &dupeGroup{Size: info.Size(), Files: []string{path}, infos: []os.FileInfo{info}})
+			return nil
+		})
+		if errWalk != nil {
+			fmt.Fprintf(os.Stderr, "equal: %v\n", errWalk)
+			trouble = true
+		}
+	}
+
+	var buf []byte
+	if cfg.bufSize > 0 {
+		buf = make([]byte, cfg.bufSize)
+	}
+	h, mustVerify, _ := newHash(cfg.hashName)
+	cmp := equalfile.NewMultiple(buf, cfg.options, h, cfg.compareOnMatch || mustVerify)
+
+	// largest files first, since they are the most valuable to report
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] > sizes[j] })
+
+	var groups []*dupeGroup
+
+	for _, size := range sizes {
+		candidates := bySize[size]
+		if len(candidates) < 2 {
+			continue
+		}
+
+		var sameSize []*dupeGroup
+	CANDIDATES:
+		for _, f := range candidates {
+			path, info := f.Files[0], f.infos[0]
+			for _, g := range sameSize {
+				if !cfg.hardLinks && hasInode(g.infos, info) {
+					continue CANDIDATES // hard link to a file already seen
+				}
+				equal, err := cmp.CompareFile(g.Files[0], path)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "equal(%s,%s): error: %v\n", g.Files[0], path, err)
+					trouble = true
+					continue CANDIDATES
+				}
+				if equal {
+					g.Files = append(g.Files, path)
+					g.infos = append(g.infos, info)
+					continue CANDIDATES
+				}
+			}
+			sameSize = append(sameSize, f)
+		}
+
+		for _, g := range sameSize {
+			if len(g.Files) < 2 {
+				continue
+			}
+			g.Reclaimable = g.Size * int64(distinctInodes(g.infos)-1)
+			groups = append(groups, g)
+		}
+	}
+
+	return groups, trouble
+}
+
+func hasInode(infos []os.FileInfo, info os.FileInfo) bool {
+	for _, i := range infos {
+		if os.SameFile(i, info) {
+			return true
+		}
+	}
+	return false
+}
+
+// distinctInodes counts files that are not hard links to each other.
+func distinctInodes(infos []os.FileInfo) int {
+	count := 0
+	for i, info := range infos {
+		if !hasInode(infos[:i], info) {
+			count++
+		}
+	}
+	return count
+}
+
+func summarizeDupes(groups []*dupeGroup) dupesSummary {
+	var sum dupesSummary
+	for _, g := range groups {
+		sum.Groups++
+		sum.Files += len(g.Files)
+		sum.Reclaimable += g.Reclaimable
+	}
+	return sum
+}
+
+func printDupes(cfg *dupesConfig, groups []*dupeGroup) {
+	sum := summarizeDupes(groups)
+
+	if cfg.format == formatJSON {
+		writeJSON(struct {
+			Hash    string       `json:"hash"`
+			Groups  []*dupeGroup `json:"groups"`
+			Summary dupesSummary `json:"summary"`
+		}{cfg.hashName, append([]*dupeGroup{}, groups...), sum}, true)
+		return
+	}
+
+	// fdupes-style: one group per block, blank line separated
+	for _, g := range groups {
+		fmt.Printf("%d bytes each:\n", g.Size)
+		for _, path := range g.Files {
+			fmt.Println(path)
+		}
+		if g.Keep != "" {
+			printLinks(cfg, g)
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("equal: %d duplicate groups, %d files, %d bytes reclaimable (hash %s)\n", sum.Groups, sum.Files, sum.Reclaimable, cfg.hashName)
+}
+
+func printLinks(cfg *dupesConfig, g *dupeGroup) {
+	var dry string
+	if cfg.dryRun {
+		dry = " (dry run)"
+	}
+	fmt.Printf("keep %s\n", g.Keep)
+	for _, a := range g.Linked {
+		if a.Error != "" {
+			fmt.Printf("failed %s: %s\n", a.Path, a.Error)
+			continue
+		}
+		fmt.Printf("%slink %s -> %s%s\n", cfg.link, a.Path, g.Keep, dry)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// describeGroups renders groups as "size reclaimable: files", with files
+// relative to dir.
+func describeGroups(t *testing.T, dir string, groups []*dupeGroup) []string {
+	t.Helper()
+	var list []string
+	for _, g := range groups {
+		var files []string
+		for _, path := range g.Files {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files = append(files, filepath.ToSlash(rel))
+		}
+		list = append(list, fmt.Sprintf("%d %d: %s", g.Size, g.Reclaimable, strings.Join(files, " ")))
+	}
+	return list
+}
+
+func TestFindDupes(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	writeTree(t, dir, map[string]string{
+		"big/a":   "0123456789",
+		"big/b":   "0123456789",
+		"big/c":   "abcdefghij", // same size, other content
+		"mid/m1":  "12345",
+		"mid/m2":  "12345",
+		"small/x": "ab",
+		"small/y": "ab",
+		"empty1":  "",
+		"empty2":  "",
+	})
+	if err := os.Link(filepath.Join(dir, "big", "a"), filepath.Join(dir, "big", "hard")); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+
+	table := []struct {
+		name      string
+		minSize   int64
+		empty     bool
+		hardLinks bool
+		roots     []string
+		want      []string
+	}{
+		{
+			name:    "default",
+			minSize: 1,
+			roots:   []string{dir},
+			want:    []string{"10 10: big/a big/b", "5 5: mid/m1 mid/m2", "2 2: small/x small/y"},
+		},
+		{
+			name:      "hard links",
+			minSize:   1,
+			hardLinks: true,
+			roots:     []string{dir},
+			// the hard link shares the inode of big/a, so it frees nothing
+			want: []string{"10 10: big/a big/b big/hard", "5 5: mid/m1 mid/m2", "2 2: small/x small/y"},
+		},
+		{
+			name:    "min size",
+			minSize: 3,
+			roots:   []string{dir},
+			want:    []string{"10 10: big/a big/b", "5 5: mid/m1 mid/m2"},
+		},
+		{
+			name:    "empty",
+			minSize: 0,
+			empty:   true,
+			roots:   []string{dir},
+			want:    []string{"10 10: big/a big/b", "5 5: mid/m1 mid/m2", "2 2: small/x small/y", "0 0: empty1 empty2"},
+		},
+		{
+			name:    "overlapping roots",
+			minSize: 1,
+			roots:   []string{filepath.Join(dir, "big") + string(filepath.Separator), dir, filepath.Join(dir, "mid")},
+			want:    []string{"10 10: big/a big/b", "5 5: mid/m1 mid/m2", "2 2: small/x small/y"},
+		},
+	}
+
+	for _, x := range table {
+		cfg := &dupesConfig{hashName: defaultHash, minSize: x.minSize, empty: x.empty, hardLinks: x.hardLinks}
+		groups, trouble := findDupes(cfg, x.roots)
+		if trouble {
+			t.Errorf("%s: unexpected trouble", x.name)
+		}
+		if got := describeGroups(t, dir, groups); !reflect.DeepEqual(got, x.want) {
+			t.Errorf("%s: got groups %q expected %q", x.name, got, x.want)
+		}
+	}
+}
+
+func TestDistinctInodes(t *testing.T) {
+	dir, keep, dup := dupeTree(t)
+	defer os.RemoveAll(dir)
+	hard := filepath.Join(dir, "hard")
+	if err := os.Link(keep, hard); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+
+	var infos []os.FileInfo
+	for _, path := range []string{keep, hard, dup, keep} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		infos = append(infos, info)
+	}
+
+	for i, want := range []int{0, 1, 1, 2, 2} {
+		if got := distinctInodes(infos[:i]); got != want {
+			t.Errorf("distinctInodes(%d infos): got %d expected %d", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/udhos/equalfile"
+)
+
+const defaultHash = "sha256"
+
+type hashAlgo struct {
+	new func() hash.Hash
+
+	// Hashes that are not collision resistant (non-cryptographic, or
+	// broken like md5 and sha1) can be fooled by crafted inputs, so a
+	// hash match must be confirmed by comparing bytes.
+	collisionResistant bool
+
+	// Seeded hashes give different digests in every process, so they
+	// can't check sums recorded by another run, as in a manifest.
+	seeded bool
+}
+
+var hashes = map[string]hashAlgo{
+	"sha256":  {sha256.New, true, false},
+	"sha512":  {sha512.New, true, false},
+	"sha1":    {sha1.New, false, false},
+	"md5":     {md5.New, false, false},
+	"crc32c":  {func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) }, false, false},
+	"crc64":   {func() hash.Hash { return crc64.New(crc64.MakeTable(crc64.ECMA)) }, false, false},
+	"fnv128a": {fnv.New128a, false, false},
+	"maphash": {equalfile.NewFastHash, false, true},
+}
+
+func hashNames() string {
+	return listHashes(false)
+}
+
+// manifestHashNames lists the hashes usable with checksum manifests.
+func manifestHashNames() string {
+	return listHashes(true)
+}
+
+func listHashes(unseededOnly bool) string {
+	names := make([]string, 0, len(hashes))
+	for name, h := range hashes {
+		if unseededOnly && h.seeded {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// newHash returns the hash algorithm with the given name, and whether
+// hash matches must be verified byte-by-byte.
+func newHash(name string) (hash.Hash, bool, error) {
+	h, found := hashes[name]
+	if !found {
+		return nil, false, fmt.Errorf("unknown hash [%s]: expecting one of %s", name, hashNames())
+	}
+	return h.new(), !h.collisionResistant, nil
+}
+
+// checkManifestHash fails if name is unknown or a seeded hash, whose
+// digests never match those recorded in a manifest.
+func checkManifestHash(name string) error {
+	h, found := hashes[name]
+	if !found {
+		return fmt.Errorf("unknown hash [%s]: expecting one of %s", name, manifestHashNames())
+	}
+	if h.seeded {
+		return fmt.Errorf("hash [%s] is seeded per process and can't verify manifests: expecting one of %s", name, manifestHashNames())
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/udhos/equalfile"
+)
+
+const (
+	linkHard = "hard"
+	linkSym  = "sym"
+)
+
+const (
+	keepOldest       = "oldest"
+	keepNewest       = "newest"
+	keepFirstPath    = "first-path"
+	keepPathPriority = "path-priority"
+)
+
+// rename is replaced by tests.
+var rename = os.Rename
+
+// linkAction records the replacement of a duplicate by a link to the kept file.
+type linkAction struct {
+	Path  string `json:"path"`
+	Error string `json:"error,omitempty"`
+}
+
+// chooseKeeper returns the index of the file in g that should be kept.
+// roots gives the priority order for keepPathPriority.
+func chooseKeeper(g *dupeGroup, keep string, roots []string) int {
+	best := 0
+	for i := 1; i < len(g.Files); i++ {
+		switch keep {
+		case keepOldest:
+			if g.infos[i].ModTime().Before(g.infos[best].ModTime()) {
+				best = i
+			}
+		case keepNewest:
+			if g.infos[i].ModTime().After(g.infos[best].ModTime()) {
+				best = i
+			}
+		case keepPathPriority:
+			if p, b := rootPriority(g.Files[i], roots), rootPriority(g.Files[best], roots); p < b || (p == b && g.Files[i] < g.Files[best]) {
+				best = i
+			}
+		default: // keepFirstPath
+			if g.Files[i] < g.Files[best] {
+				best = i
+			}
+		}
+	}
+	return best
+}
+
+// rootPriority returns the index of the first root containing path.
+func rootPriority(path string, roots []string) int {
+	for i, root := range roots {
+		root = filepath.Clean(root)
+		if path == root || strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return i
+		}
+	}
+	return len(roots)
+}
+
+// linkDupes replaces every duplicate in the groups by a link to the file
+// chosen to be kept. Each duplicate is compared again byte-by-byte right
+// before being replaced, since files may have changed after hashing.
+func linkDupes(cfg *dupesConfig, groups []*dupeGroup, roots []string) bool {
+	var buf []byte
+	if cfg.bufSize > 0 {
+		buf = make([]byte, cfg.bufSize)
+	}
+	verify := equalfile.New(buf, equalfile.Options{
+		Debug:            cfg.options.Debug,
+		Limiter:          cfg.options.Limiter,
+		NoAtime:          cfg.options.NoAtime,
+		DropCache:        cfg.options.DropCache,
+		Progress:         cfg.options.Progress,
+		ProgressInterval: cfg.options.ProgressInterval,
+	})
+
+	trouble := false
+
+	for _, g := range groups {
+		k := chooseKeeper(g, cfg.keep, roots)
+		g.Keep = g.Files[k]
+		g.Linked = []linkAction{}
+
+		for i, path := range g.Files {
+			if i == k || os.SameFile(g.infos[i], g.infos[k]) {
+				continue // nothing to reclaim
+			}
+
+			action := linkAction{Path: path}
+
+			if err := replaceWithLink(cfg, verify, g.Keep, path); err != nil {
+				fmt.Fprintf(os.Stderr, "equal: %v\n", err)
+				action.Error = err.Error()
+				trouble = true
+			}
+
+			g.Linked = append(g.Linked, action)
+		}
+	}
+
+	return trouble
+}
+
+func replaceWithLink(cfg *dupesConfig, verify *equalfile.Cmp, keep, path string) error {
+	equal, err := verify.CompareFile(keep, path)
+	if err != nil {
+		return err
+	}
+	if !equal {
+		return fmt.Errorf("%s: changed since scanning, not replaced", path)
+	}
+
+	if cfg.dryRun {
+		return nil
+	}
+
+	// Link to a temporary name in the same directory, then rename it over
+	// the duplicate, so the path never goes missing.
+	tmp := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.equal-%d.tmp", filepath.Base(path), os.Getpid()))
+
+	switch cfg.link {
+	case linkHard:
+		err = os.Link(keep, tmp)
+		if le, isLinkErr := err.(*os.LinkError); isLinkErr && le.Err == syscall.EXDEV {
+			return fmt.Errorf("%s: refusing to hard link across filesystems to %s", path, keep)
+		}
+	default:
+		target, errAbs := filepath.Abs(keep)
+		if errAbs != nil {
+			return errAbs
+		}
+		err = os.Symlink(target, tmp)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/udhos/equalfile"
+)
+
+// fakeInfo is an os.FileInfo with only a modification time.
+type fakeInfo struct {
+	os.FileInfo
+	mtime time.Time
+}
+
+func (f fakeInfo) ModTime() time.Time { return f.mtime }
+
+func TestChooseKeeper(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := &dupeGroup{
+		Files: []string{"r2/b", "r1/z", "r3/a", "r1/y"},
+		infos: []os.FileInfo{
+			fakeInfo{mtime: base.Add(2 * time.Hour)},
+			fakeInfo{mtime: base.Add(3 * time.Hour)},
+			fakeInfo{mtime: base},
+			fakeInfo{mtime: base.Add(time.Hour)},
+		},
+	}
+	roots := []string{"r1/", "r2", "r3"}
+
+	expected := map[string]string{
+		keepOldest:       "r3/a",
+		keepNewest:       "r1/z",
+		keepFirstPath:    "r1/y",
+		keepPathPriority: "r1/y",
+	}
+	for keep, path := range expected {
+		if got := g.Files[chooseKeeper(g, keep, roots)]; got != path {
+			t.Errorf("keep %s: got %s expected %s", keep, got, path)
+		}
+	}
+
+	// roots order, not path order, decides
+	if got := g.Files[chooseKeeper(g, keepPathPriority, []string{"r3", "r2", "r1"})]; got != "r3/a" {
+		t.Errorf("keep %s: got %s expected r3/a", keepPathPriority, got)
+	}
+}
+
+func TestRootPriority(t *testing.T) {
+	roots := []string{"a/b", "a", "c/"}
+	expected := map[string]int{
+		"a/b":    0,
+		"a/b/x":  0,
+		"a/bx":   1, // not under a/b
+		"a/x":    1,
+		"a":      1,
+		"c/x":    2,
+		"d/x":    3,
+		"ab/x/y": 3,
+	}
+	for path, p := range expected {
+		if got := rootPriority(filepath.FromSlash(path), roots); got != p {
+			t.Errorf("rootPriority(%s): got %d expected %d", path, got, p)
+		}
+	}
+}
+
+// dupeTree creates a directory with keep and dup, holding the same
+// content, and returns their paths.
+func dupeTree(t *testing.T) (dir, keep, dup string) {
+	t.Helper()
+	dir = tempDir(t)
+	writeTree(t, dir, map[string]string{"keep": "same content", "sub/dup": "same content"})
+	return dir, filepath.Join(dir, "keep"), filepath.Join(dir, "sub", "dup")
+}
+
+func readString(t *testing.T, path string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// listDir returns the names in dir.
+func listDir(t *testing.T, dir string) []string {
+	t.Helper()
+	list, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, info := range list {
+		names = append(names, info.Name())
+	}
+	return names
+}
+
+func TestReplaceWithHardLink(t *testing.T) {
+	dir, keep, dup := dupeTree(t)
+	defer os.RemoveAll(dir)
+
+	cfg := &dupesConfig{link: linkHard}
+	if err := replaceWithLink(cfg, equalfile.New(nil, equalfile.Options{}), keep, dup); err != nil {
+		t.Fatalf("replaceWithLink: %v", err)
+	}
+
+	info1, _ := os.Stat(keep)
+	info2, err := os.Lstat(dup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info2.Mode().IsRegular() || !os.SameFile(info1, info2) {
+		t.Errorf("%s: expected hard link to %s", dup, keep)
+	}
+	if names := listDir(t, filepath.Dir(dup)); len(names) != 1 {
+		t.Errorf("unexpected files left: %v", names)
+	}
+}
+
+func TestReplaceWithSymlink(t *testing.T) {
+	dir, keep, dup := dupeTree(t)
+	defer os.RemoveAll(dir)
+
+	cfg := &dupesConfig{link: linkSym}
+	if err := replaceWithLink(cfg, equalfile.New(nil, equalfile.Options{}), keep, dup); err != nil {
+		t.Fatalf("replaceWithLink: %v", err)
+	}
+
+	info, err := os.Lstat(dup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("%s: expected symbolic link, got %v", dup, info.Mode())
+	}
+	target, _ := os.Readlink(dup)
+	if abs, _ := filepath.Abs(keep); target != abs {
+		t.Errorf("%s: link to %s, expected %s", dup, target, abs)
+	}
+	if got := readString(t, dup); got != "same content" {
+		t.Errorf("%s: read %q through link", dup, got)
+	}
+}
+
+func TestReplaceChangedFile(t *testing.T) {
+	dir, keep, dup := dupeTree(t)
+	defer os.RemoveAll(dir)
+
+	// changed after scanning, keeping the size
+	if err := ioutil.WriteFile(dup, []byte("diff content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, link := range []string{linkHard, linkSym} {
+		cfg := &dupesConfig{link: link}
+		if err := replaceWithLink(cfg, equalfile.New(nil, equalfile.Options{}), keep, dup); err == nil {
+			t.Errorf("%s link: expected changed file to be refused", link)
+		}
+		info, err := os.Lstat(dup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.Mode().IsRegular() || readString(t, dup) != "diff content" {
+			t.Errorf("%s link: changed file was touched", link)
+		}
+	}
+}
+
+func TestReplaceRenameSequence(t *testing.T) {
+	defer func() { rename = os.Rename }()
+
+	for _, link := range []string{linkHard, linkSym} {
+		dir, keep, dup := dupeTree(t)
+		defer os.RemoveAll(dir)
+
+		// the link is made under a temporary name in the same directory,
+		// while the duplicate is still in place
+		var renamed bool
+		rename = func(oldpath, newpath string) error {
+			renamed = true
+			if filepath.Dir(oldpath) != filepath.Dir(dup) || oldpath == dup || newpath != dup {
+				t.Errorf("%s link: unexpected rename %s to %s", link, oldpath, newpath)
+			}
+			if got := readString(t, oldpath); got != "same content" {
+				t.Errorf("%s link: temporary link reads %q", link, got)
+			}
+			if info, err := os.Lstat(dup); err != nil || !info.Mode().IsRegular() {
+				t.Errorf("%s link: duplicate gone before rename: %v", link, err)
+			}
+			return os.Rename(oldpath, newpath)
+		}
+		if err := replaceWithLink(&dupesConfig{link: link}, equalfile.New(nil, equalfile.Options{}), keep, dup); err != nil {
+			t.Errorf("%s link: replaceWithLink: %v", link, err)
+		}
+		if !renamed {
+			t.Errorf("%s link: rename not called", link)
+		}
+	}
+}
+
+func TestReplaceRenameFailure(t *testing.T) {
+	defer func() { rename = os.Rename }()
+	rename = func(oldpath, newpath string) error {
+		return errors.New("rename failed")
+	}
+
+	for _, link := range []string{linkHard, linkSym} {
+		dir, keep, dup := dupeTree(t)
+		defer os.RemoveAll(dir)
+
+		if err := replaceWithLink(&dupesConfig{link: link}, equalfile.New(nil, equalfile.Options{}), keep, dup); err == nil {
+			t.Errorf("%s link: expected error", link)
+		}
+		if names := listDir(t, filepath.Dir(dup)); len(names) != 1 || names[0] != "dup" {
+			t.Errorf("%s link: temporary link not cleaned up: %v", link, names)
+		}
+		info, err := os.Lstat(dup)
+		if err != nil || !info.Mode().IsRegular() || readString(t, dup) != "same content" {
+			t.Errorf("%s link: duplicate was touched: %v", link, err)
+		}
+	}
+}
+
+func TestLinkDupesDryRun(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	files := map[string]string{"a/1": "dup", "a/2": "dup", "b/3": "dup", "b/4": "unique"}
+	writeTree(t, dir, files)
+
+	before := map[string]os.FileInfo{}
+	for name := range files {
+		info, err := os.Lstat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		before[name] = info
+	}
+
+	for _, link := range []string{linkHard, linkSym} {
+		cfg := &dupesConfig{hashName: defaultHash, minSize: 1, link: link, keep: keepFirstPath, dryRun: true}
+		roots := []string{dir}
+		groups, trouble := findDupes(cfg, roots)
+		if trouble || len(groups) != 1 || len(groups[0].Files) != 3 {
+			t.Fatalf("findDupes: unexpected %v %v", groups, trouble)
+		}
+		if linkDupes(cfg, groups, roots) {
+			t.Errorf("%s link: linkDupes reported trouble", link)
+		}
+		if g := groups[0]; g.Keep != filepath.Join(dir, "a", "1") || len(g.Linked) != 2 {
+			t.Errorf("%s link: unexpected plan: keep %s linked %v", link, g.Keep, g.Linked)
+		}
+
+		for name, content := range files {
+			path := filepath.Join(dir, name)
+			info, err := os.Lstat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !os.SameFile(info, before[name]) || !info.Mode().IsRegular() || !info.ModTime().Equal(before[name].ModTime()) || readString(t, path) != content {
+				t.Errorf("%s link: %s changed by dry run", link, name)
+			}
+		}
+		for _, d := range []string{"a", "b"} {
+			if names := listDir(t, filepath.Join(dir, d)); len(names) != 2 {
+				t.Errorf("%s link: unexpected files in %s: %v", link, d, names)
+			}
+		}
+	}
+}
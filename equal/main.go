@@ -1,121 +1,304 @@
 package main
 
 import (
-	"crypto/sha256"
+	"flag"
 	"fmt"
 	"os"
 	"runtime"
-	"strconv"
 
 	"github.com/udhos/equalfile"
 )
 
 const (
 	version = "0.0"
+	stdin   = "-"
 )
 
-func main() {
-	fmt.Printf("equal version %s runtime %v GOMAXPROCS=%d\n", version, runtime.Version(), runtime.GOMAXPROCS(0))
+const (
+	symlinkFollow = "follow"
+	symlinkTarget = "target"
+	symlinkType   = "type"
+)
+
+var symlinkModes = map[string]equalfile.SymlinkMode{
+	symlinkFollow: equalfile.SymlinkFollow,
+	symlinkTarget: equalfile.SymlinkCompareTarget,
+	symlinkType:   equalfile.SymlinkTypeMismatch,
+}
+
+// Exit status follows cmp and diff conventions.
+const (
+	exitEqual   = 0
+	exitDiffer  = 1
+	exitTrouble = 2 // open/read errors, invalid options
+)
+
+type config struct {
+	options        equalfile.Options
+	bufSize        int64
+	noHash         bool
+	hashName       string
+	compareOnMatch bool
+	quiet          bool
+	recursive      bool
+	format         string
+	lineSet        bool
+	progress       bool
+}
 
-	if len(os.Args) < 3 {
-		fmt.Printf("usage: equal file1 file2 [...fileN]\n")
-		os.Exit(2)
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "dupes":
+			os.Exit(dupes(os.Args[2:]))
+		case "verify":
+			os.Exit(verify(os.Args[2:]))
+		}
 	}
 
-	if compareFiles(os.Args[1:]) {
-		fmt.Println("equal: files match")
+	cfg, files := parseFlags()
+
+	status := compareFiles(cfg, files)
+	if status == exitEqual {
 		return // cleaner than os.Exit(0)
 	}
 
-	fmt.Println("equal: files differ")
-	os.Exit(1)
+	os.Exit(status)
 }
 
-func compareFiles(files []string) bool {
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: equal [flags] file1 file2 [...fileN]\n")
+	fmt.Fprintf(os.Stderr, "       equal -r [flags] dir1 dir2\n")
+	fmt.Fprintf(os.Stderr, "       equal dupes [flags] root1 [...rootN]\n")
+	fmt.Fprintf(os.Stderr, "       equal verify [flags] SHA256SUMS [...manifestN]\n")
+	fmt.Fprintf(os.Stderr, "Use '-' as file1 or file2 to read standard input.\n")
+	flag.PrintDefaults()
+}
 
-	options := equalfile.Options{}
+// parseFlags reads command-line flags. Flags left unset fall back to
+// the legacy environment variables (DEBUG, FORCE_FILE_READ, MAX_SIZE,
+// BUF_SIZE, NO_HASH and COMPARE_ON_MATCH).
+func parseFlags() (*config, []string) {
+	cfg := &config{}
 
-	if str := os.Getenv("DEBUG"); str != "" {
-		options.Debug = true
-	}
+	var maxSize, bufSize, symlinks, metadata, bwlimit string
+	var showVersion bool
 
-	fmt.Printf("Debug=%v DEBUG=[%s]\n", options.Debug, os.Getenv("DEBUG"))
+	flag.Usage = usage
+	flag.StringVar(&maxSize, "max-size", os.Getenv("MAX_SIZE"), "stop comparing after this many bytes (accepts suffixes like 64K, 10G) [MAX_SIZE]")
+	flag.StringVar(&bufSize, "buf-size", os.Getenv("BUF_SIZE"), "read buffer size (accepts suffixes like 64K, 1M) [BUF_SIZE]")
+	flag.BoolVar(&cfg.noHash, "no-hash", envBool("NO_HASH"), "disable multiple mode hashing [NO_HASH]")
+	flag.StringVar(&cfg.hashName, "hash", defaultHash, "hash algorithm for multiple mode: "+hashNames())
+	flag.BoolVar(&cfg.compareOnMatch, "verify-hash", envBool("COMPARE_ON_MATCH"), "compare bytes when hashes match [COMPARE_ON_MATCH]")
+	flag.BoolVar(&cfg.options.ForceFileRead, "force-read", envBool("FORCE_FILE_READ"), "always read files, even when the filesystem reports same file [FORCE_FILE_READ]")
+	flag.StringVar(&symlinks, "symlinks", symlinkFollow, "symbolic links: follow, target (compare link targets) or type (link versus non-link is a difference)")
+	flag.StringVar(&metadata, "metadata", "", "also compare metadata: comma separated list of mode, owner, mtime, xattr")
+	flag.DurationVar(&cfg.options.ModTimeTolerance, "mtime-tolerance", 0, "allowed modification time difference for --metadata=mtime")
+	flag.BoolVar(&cfg.options.Sparse, "sparse", false, "skip holes in sparse files (Linux SEEK_DATA/SEEK_HOLE)")
+	flag.BoolVar(&cfg.lineSet, "line-set", false, "compare lines regardless of order, counting repeated lines")
+	flag.StringVar(&bwlimit, "bwlimit", "", "limit reading to this many bytes per second (accepts suffixes like 50M)")
+	flag.IntVar(&cfg.options.IOPSLimit, "iops-limit", 0, "limit reading to this many reads per second")
+	flag.BoolVar(&cfg.options.NoAtime, "noatime", false, "open files without updating access times, where permitted (Linux O_NOATIME)")
+	flag.BoolVar(&cfg.options.DropCache, "drop-cache", false, "keep compared files from filling the page cache (Linux posix_fadvise)")
+	flag.BoolVar(&cfg.progress, "progress", false, "show progress on stderr: a bar with throughput and ETA on a terminal, periodic lines otherwise")
+	flag.BoolVar(&cfg.options.Debug, "debug", envBool("DEBUG"), "enable debugging to stdout [DEBUG]")
+	flag.BoolVar(&cfg.recursive, "r", false, "compare directories recursively, like diff -rq")
+	flag.StringVar(&cfg.format, "format", formatText, "output format: text, json or jsonl (one JSON object per line)")
+	flag.BoolVar(&cfg.quiet, "quiet", false, "print nothing, report result only through exit status")
+	flag.BoolVar(&showVersion, "version", false, "show version and exit")
+	flag.Parse()
 
-	if str := os.Getenv("FORCE_FILE_READ"); str != "" {
-		options.ForceFileRead = true
+	if showVersion {
+		fmt.Printf("equal version %s runtime %v GOMAXPROCS=%d\n", version, runtime.Version(), runtime.GOMAXPROCS(0))
+		os.Exit(0)
 	}
 
-	if str := os.Getenv("MAX_SIZE"); str != "" {
+	if maxSize != "" {
 		var errConv error
-		options.MaxSize, errConv = strconv.ParseInt(str, 10, 64)
+		cfg.options.MaxSize, errConv = parseSize(maxSize)
 		if errConv != nil {
-			fmt.Printf("Failure parsing MAX_SIZE=[%s]: %v\n", os.Getenv("MAX_SIZE"), errConv)
+			fmt.Fprintf(os.Stderr, "equal: bad max size [%s]: %v\n", maxSize, errConv)
+			os.Exit(exitTrouble)
 		}
 	}
 
-	var bufSize int64
-	if str := os.Getenv("BUF_SIZE"); str != "" {
+	if bufSize != "" {
 		var errConv error
-		bufSize, errConv = strconv.ParseInt(str, 10, 64)
+		cfg.bufSize, errConv = parseSize(bufSize)
 		if errConv != nil {
-			fmt.Printf("Failure parsing BUF_SIZE=[%s]: %v\n", os.Getenv("BUF_SIZE"), errConv)
+			fmt.Fprintf(os.Stderr, "equal: bad buffer size [%s]: %v\n", bufSize, errConv)
+			os.Exit(exitTrouble)
+		}
+	}
+
+	if bwlimit != "" {
+		var errConv error
+		if cfg.options.BandwidthLimit, errConv = parseRate(bwlimit); errConv != nil {
+			fmt.Fprintf(os.Stderr, "equal: bad bandwidth limit [%s]: %v\n", bwlimit, errConv)
+			os.Exit(exitTrouble)
+		}
+	}
+	shareLimits(&cfg.options)
+
+	if _, _, errHash := newHash(cfg.hashName); errHash != nil {
+		fmt.Fprintf(os.Stderr, "equal: %v\n", errHash)
+		os.Exit(exitTrouble)
+	}
+
+	mode, found := symlinkModes[symlinks]
+	if !found {
+		fmt.Fprintf(os.Stderr, "equal: bad symlinks [%s]: expecting %s, %s or %s\n", symlinks, symlinkFollow, symlinkTarget, symlinkType)
+		os.Exit(exitTrouble)
+	}
+	cfg.options.Symlinks = mode
+
+	var errMeta error
+	if cfg.options.Metadata, errMeta = equalfile.ParseMetadata(metadata); errMeta != nil {
+		fmt.Fprintf(os.Stderr, "equal: %v\n", errMeta)
+		os.Exit(exitTrouble)
+	}
+
+	switch cfg.format {
+	case formatText, formatJSON, formatJSONL:
+	default:
+		fmt.Fprintf(os.Stderr, "equal: bad format [%s]: expecting %s, %s or %s\n", cfg.format, formatText, formatJSON, formatJSONL)
+		os.Exit(exitTrouble)
+	}
+
+	if cfg.quiet {
+		cfg.options.Debug = false
+		cfg.progress = false
+	}
+	if cfg.progress {
+		enableProgress(&cfg.options)
+	}
+
+	files := flag.Args()
+	if len(files) < 2 || (cfg.recursive && len(files) != 2) {
+		usage()
+		os.Exit(exitTrouble)
+	}
+
+	if cfg.lineSet && cfg.recursive {
+		fmt.Fprintf(os.Stderr, "equal: --line-set can't be used with -r\n")
+		os.Exit(exitTrouble)
+	}
+
+	for _, f := range files {
+		// standard input can be read only once
+		if f == stdin && (len(files) != 2 || cfg.recursive || files[0] == files[1]) {
+			fmt.Fprintf(os.Stderr, "equal: standard input '-' can only be compared against a single file\n")
+			os.Exit(exitTrouble)
 		}
 	}
 
-	var noHash bool
-	if str := os.Getenv("NO_HASH"); str != "" {
-		noHash = true
+	if cfg.options.Debug {
+		fmt.Printf("equal version %s runtime %v GOMAXPROCS=%d\n", version, runtime.Version(), runtime.GOMAXPROCS(0))
+		fmt.Printf("Debug=%v ForceFileRead=%v MaxSize=%d bufSize=%d\n", cfg.options.Debug, cfg.options.ForceFileRead, cfg.options.MaxSize, cfg.bufSize)
+		fmt.Printf("noHash=%v hash=%s compareOnMatch=%v recursive=%v\n", cfg.noHash, cfg.hashName, cfg.compareOnMatch, cfg.recursive)
+	}
+
+	return cfg, files
+}
+
+// shareLimits makes every Cmp created with opt share the same rate limits.
+func shareLimits(opt *equalfile.Options) {
+	if opt.BandwidthLimit > 0 || opt.IOPSLimit > 0 {
+		opt.Limiter = equalfile.NewLimiter(opt.BandwidthLimit, opt.IOPSLimit)
+	}
+}
+
+func envBool(name string) bool {
+	return os.Getenv(name) != ""
+}
+
+// compareFile compares two paths, where "-" means standard input.
+func compareFile(cmp *equalfile.Cmp, path1, path2 string) (equalfile.Result, bool, error) {
+	switch stdin {
+	case path1:
+		equal, err := cmp.CompareReaderFile(os.Stdin, path2)
+		return cmp.LastResult(), equal, err
+	case path2:
+		equal, err := cmp.CompareReaderFile(os.Stdin, path1)
+		r := cmp.LastResult()
+		r.Size1, r.Size2 = r.Size2, r.Size1
+		return r, equal, err
 	}
+	equal, err := cmp.CompareFile(path1, path2)
+	return cmp.LastResult(), equal, err
+}
 
-	var compareOnMatch bool
-	if str := os.Getenv("COMPARE_ON_MATCH"); str != "" {
-		compareOnMatch = true
+// compareLineSet is like compareFile, comparing files as sets of lines.
+func compareLineSet(cmp *equalfile.Cmp, path1, path2 string) (equalfile.Result, []equalfile.LineDiff, bool, error) {
+	var diffs []equalfile.LineDiff
+	report := func(d equalfile.LineDiff) {
+		diffs = append(diffs, d)
 	}
 
-	if options.Debug {
-		fmt.Printf("ForceFileRead=%v FORCE_FILE_READ=[%s]\n", options.ForceFileRead, os.Getenv("FORCE_FILE_READ"))
-		fmt.Printf("MaxSize=%d MAX_SIZE=[%s]\n", options.MaxSize, os.Getenv("MAX_SIZE"))
-		fmt.Printf("bufSize=%d BUF_SIZE=[%s]\n", bufSize, os.Getenv("BUF_SIZE"))
-		fmt.Printf("noHash=%v NO_HASH=[%s]\n", noHash, os.Getenv("NO_HASH"))
-		fmt.Printf("compareOnMatch=%v COMPARE_ON_MATCH=[%s]\n", compareOnMatch, os.Getenv("COMPARE_ON_MATCH"))
+	var equal bool
+	var err error
+	switch stdin {
+	case path1:
+		f, errOpen := os.Open(path2)
+		if errOpen != nil {
+			return cmp.LastResult(), nil, false, errOpen
+		}
+		defer f.Close()
+		equal, err = cmp.CompareLineSet(os.Stdin, f, report)
+	case path2:
+		f, errOpen := os.Open(path1)
+		if errOpen != nil {
+			return cmp.LastResult(), nil, false, errOpen
+		}
+		defer f.Close()
+		equal, err = cmp.CompareLineSet(f, os.Stdin, report)
+	default:
+		equal, err = cmp.CompareFileLineSet(path1, path2, report)
 	}
+	return cmp.LastResult(), diffs, equal, err
+}
+
+func compareFiles(cfg *config, files []string) int {
 
 	var buf []byte
-	if bufSize > 0 {
-		buf = make([]byte, bufSize)
+	if cfg.bufSize > 0 {
+		buf = make([]byte, cfg.bufSize)
 	}
 
 	var cmp *equalfile.Cmp
+	var hashAlgo string
 
-	if len(files) > 2 && !noHash {
-		cmp = equalfile.NewMultiple(buf, options, sha256.New(), compareOnMatch)
+	// Recursive mode shares the hash cache across the whole tree walk.
+	if (len(files) > 2 || cfg.recursive) && !cfg.noHash && !cfg.lineSet {
+		h, mustVerify, _ := newHash(cfg.hashName)
+		cmp = equalfile.NewMultiple(buf, cfg.options, h, cfg.compareOnMatch || mustVerify)
+		hashAlgo = cfg.hashName
 	} else {
-		cmp = equalfile.New(buf, options)
+		cmp = equalfile.New(buf, cfg.options)
 	}
 
-	match := true
+	out := newOutput(cfg)
+	out.summary.Hash = hashAlgo
+
+	if cfg.recursive {
+		compareTree(cmp, out, hashAlgo, files[0], files[1])
+		return out.finish()
+	}
 
 	for i := 0; i < len(files)-1; i++ {
 		p0 := files[i]
 		for _, p := range files[i+1:] {
-			equal, err := cmp.CompareFile(p0, p)
-			if err != nil {
-				fmt.Printf("equal(%s,%s): error: %v\n", p0, p, err)
-				match = false
+			if cfg.lineSet {
+				r, diffs, equal, err := compareLineSet(cmp, p0, p)
+				rec := compared(r, hashAlgo, p0, p, equal, err)
+				rec.Lines = lineRecords(diffs)
+				out.pair(rec)
 				continue
 			}
-			if !equal {
-				if options.Debug {
-					fmt.Printf("equal(%s,%s): files differ\n", p0, p)
-				}
-				match = false
-				continue
-			}
-			if options.Debug {
-				fmt.Printf("equal(%s,%s): files match\n", p0, p)
-			}
+			r, equal, err := compareFile(cmp, p0, p)
+			out.pair(compared(r, hashAlgo, p0, p, equal, err))
 		}
 	}
 
-	return match
+	return out.finish()
 }
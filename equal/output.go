@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/udhos/equalfile"
+)
+
+const (
+	formatText  = "text"
+	formatJSON  = "json"
+	formatJSONL = "jsonl"
+)
+
+const (
+	verdictEqual     = "equal"
+	verdictDifferent = "different"
+	verdictError     = "error"
+)
+
+// Reasons used by the equal command in addition to equalfile.Reason*.
+const (
+	reasonOnlyIn1      = "only in path1"
+	reasonOnlyIn2      = "only in path2"
+	reasonTypeMismatch = "type mismatch"
+	reasonSpecialFile  = "special file" // fifo, socket or device, never read
+)
+
+// pairRecord reports the comparison of one pair of paths.
+type pairRecord struct {
+	Type      string       `json:"type"`
+	Path1     string       `json:"path1"`
+	Path2     string       `json:"path2"`
+	Verdict   string       `json:"verdict"`
+	Reason    string       `json:"reason,omitempty"`
+	Offset    *int64       `json:"mismatch_offset,omitempty"`
+	Size1     *int64       `json:"size1,omitempty"`
+	Size2     *int64       `json:"size2,omitempty"`
+	FileType1 string       `json:"file_type1,omitempty"`
+	FileType2 string       `json:"file_type2,omitempty"`
+	HashAlgo  string       `json:"hash,omitempty"`
+	Hash1     string       `json:"hash1,omitempty"`
+	Hash2     string       `json:"hash2,omitempty"`
+	Metadata  []string     `json:"metadata_diff,omitempty"`
+	Lines     []lineRecord `json:"line_diff,omitempty"`
+	Error     string       `json:"error,omitempty"`
+}
+
+// lineRecord reports a line counted differently by --line-set.
+type lineRecord struct {
+	Line   string `json:"line"`
+	Count1 int64  `json:"count1"`
+	Count2 int64  `json:"count2"`
+}
+
+func lineRecords(diffs []equalfile.LineDiff) []lineRecord {
+	var lines []lineRecord
+	for _, d := range diffs {
+		lines = append(lines, lineRecord{d.Line, d.Count1, d.Count2})
+	}
+	return lines
+}
+
+// summaryRecord is emitted once after all pairs.
+type summaryRecord struct {
+	Type      string `json:"type"`
+	Pairs     int    `json:"pairs"`
+	Equal     int    `json:"equal"`
+	Different int    `json:"different"`
+	Errors    int    `json:"errors"`
+	Match     bool   `json:"match"`
+	Hash      string `json:"hash,omitempty"` // hash algorithm used in multiple mode
+}
+
+type output struct {
+	cfg     *config
+	records []pairRecord
+	summary summaryRecord
+}
+
+func newOutput(cfg *config) *output {
+	return &output{
+		cfg:     cfg,
+		records: []pairRecord{},
+		summary: summaryRecord{Type: "summary", Match: true},
+	}
+}
+
+// compared builds a record from the outcome of a Cmp comparison.
+func compared(r equalfile.Result, hashAlgo, path1, path2 string, equal bool, err error) pairRecord {
+	rec := pairRecord{Path1: path1, Path2: path2}
+	if err != nil {
+		rec.Verdict = verdictError
+		rec.Error = err.Error()
+		return rec
+	}
+
+	rec.Reason = r.Reason
+	if equal {
+		rec.Verdict = verdictEqual
+	} else {
+		rec.Verdict = verdictDifferent
+	}
+	if r.Offset >= 0 {
+		rec.Offset = &r.Offset
+	}
+	if r.Size1 >= 0 {
+		rec.Size1 = &r.Size1
+	}
+	if r.Size2 >= 0 {
+		rec.Size2 = &r.Size2
+	}
+	if r.Hash1 != nil || r.Hash2 != nil {
+		rec.HashAlgo = hashAlgo
+		rec.Hash1 = hex.EncodeToString(r.Hash1)
+		rec.Hash2 = hex.EncodeToString(r.Hash2)
+	}
+	if r.MetadataDiff != 0 {
+		rec.Metadata = strings.Split(r.MetadataDiff.String(), ",")
+	}
+	return rec
+}
+
+// pair records one comparison and returns true if the paths matched.
+func (o *output) pair(rec pairRecord) bool {
+	rec.Type = "pair"
+
+	o.summary.Pairs++
+	switch rec.Verdict {
+	case verdictEqual:
+		o.summary.Equal++
+	case verdictDifferent:
+		o.summary.Different++
+		o.summary.Match = false
+	default:
+		o.summary.Errors++
+		o.summary.Match = false
+	}
+
+	if rec.Verdict == verdictError {
+		// errors go to stderr regardless of format or quiet mode
+		fmt.Fprintf(os.Stderr, "equal(%s,%s): error: %s\n", rec.Path1, rec.Path2, rec.Error)
+	}
+
+	if o.cfg.quiet {
+		return rec.Verdict == verdictEqual
+	}
+
+	switch o.cfg.format {
+	case formatJSON:
+		o.records = append(o.records, rec)
+	case formatJSONL:
+		writeJSON(rec, false)
+	default:
+		o.text(rec)
+	}
+
+	return rec.Verdict == verdictEqual
+}
+
+func (o *output) text(rec pairRecord) {
+	switch {
+	case rec.Verdict == verdictError:
+		// already reported on stderr
+	case rec.Reason == reasonOnlyIn1:
+		dir, name := filepath.Split(rec.Path1)
+		fmt.Printf("Only in %s: %s\n", filepath.Clean(dir), name)
+	case rec.Reason == reasonOnlyIn2:
+		dir, name := filepath.Split(rec.Path2)
+		fmt.Printf("Only in %s: %s\n", filepath.Clean(dir), name)
+	case rec.Reason == reasonTypeMismatch, rec.Reason == reasonSpecialFile:
+		fmt.Printf("File %s is a %s while file %s is a %s\n", rec.Path1, rec.FileType1, rec.Path2, rec.FileType2)
+	case rec.Verdict == verdictDifferent && o.cfg.lineSet:
+		// like diff: lines missing from path2 marked '<', from path1 '>'
+		fmt.Printf("Line sets of %s and %s differ\n", rec.Path1, rec.Path2)
+		for _, l := range rec.Lines {
+			for i := l.Count2; i < l.Count1; i++ {
+				fmt.Printf("< %s\n", l.Line)
+			}
+			for i := l.Count1; i < l.Count2; i++ {
+				fmt.Printf("> %s\n", l.Line)
+			}
+		}
+	case rec.Verdict == verdictDifferent && o.cfg.recursive:
+		fmt.Printf("Files %s and %s differ%s\n", rec.Path1, rec.Path2, metadataText(rec))
+	case o.cfg.options.Debug:
+		if rec.Verdict == verdictEqual {
+			fmt.Printf("equal(%s,%s): files match\n", rec.Path1, rec.Path2)
+		} else {
+			fmt.Printf("equal(%s,%s): files differ%s\n", rec.Path1, rec.Path2, metadataText(rec))
+		}
+	}
+}
+
+func metadataText(rec pairRecord) string {
+	if len(rec.Metadata) == 0 {
+		return ""
+	}
+	return " in " + strings.Join(rec.Metadata, ", ")
+}
+
+// finish emits the summary and returns the exit status.
+func (o *output) finish() int {
+	status := exitEqual
+	switch {
+	case o.summary.Errors > 0:
+		status = exitTrouble
+	case !o.summary.Match:
+		status = exitDiffer
+	}
+
+	if o.cfg.quiet {
+		return status
+	}
+
+	switch o.cfg.format {
+	case formatJSON:
+		writeJSON(struct {
+			Results []pairRecord  `json:"results"`
+			Summary summaryRecord `json:"summary"`
+		}{o.records, o.summary}, true)
+	case formatJSONL:
+		writeJSON(o.summary, false)
+	default:
+		var hash string
+		if o.summary.Hash != "" {
+			hash = " (hash " + o.summary.Hash + ")"
+		}
+		switch status {
+		case exitEqual:
+			fmt.Printf("equal: files match%s\n", hash)
+		case exitDiffer:
+			fmt.Printf("equal: files differ%s\n", hash)
+		default:
+			fmt.Printf("equal: trouble comparing files%s\n", hash)
+		}
+	}
+
+	return status
+}
+
+func writeJSON(v interface{}, indent bool) {
+	enc := json.NewEncoder(os.Stdout)
+	if indent {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "equal: json: %v\n", err)
+	}
+}
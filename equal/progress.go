@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/udhos/equalfile"
+)
+
+// A terminal bar is redrawn often, while plain lines end up in logs.
+const (
+	terminalProgressInterval = 200 * time.Millisecond
+	plainProgressInterval    = 10 * time.Second
+)
+
+const (
+	progressBarWidth  = 24
+	progressNameWidth = 32 // tail of long paths shown next to the bar
+)
+
+// progressMeter shows progress reports on stderr: a bar redrawn in place
+// on a terminal, or plain lines otherwise.
+type progressMeter struct {
+	w        io.Writer
+	terminal bool
+	shown    bool // something was shown for the current phase
+}
+
+// enableProgress sets opt to report progress on stderr.
+func enableProgress(opt *equalfile.Options) {
+	m := &progressMeter{w: os.Stderr, terminal: isTerminal(os.Stderr)}
+	opt.Progress = m.report
+	opt.ProgressInterval = plainProgressInterval
+	if m.terminal {
+		opt.ProgressInterval = terminalProgressInterval
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func (m *progressMeter) report(p equalfile.Progress) {
+	if m.terminal {
+		// the bar is erased at the end of each phase, so it never mixes
+		// with regular output
+		if p.Final {
+			if m.shown {
+				fmt.Fprint(m.w, "\r\033[K")
+			}
+			m.shown = false
+			return
+		}
+		fmt.Fprintf(m.w, "\r\033[K%s", progressBar(p))
+		m.shown = true
+		return
+	}
+
+	// phases finished before the first periodic report are not worth a line
+	if p.Final && !m.shown {
+		return
+	}
+	fmt.Fprintf(m.w, "equal: %s\n", progressLine(p))
+	m.shown = !p.Final
+}
+
+// progressBar formats p for a terminal, like:
+// hashing file 1 [=========>              ]  40% 1.0GiB/2.5GiB 120.0MiB/s ETA 13s a/b/c
+func progressBar(p equalfile.Progress) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-14s ", p.Phase)
+	if p.Total > 0 {
+		filled := int(p.Done * progressBarWidth / p.Total)
+		if filled > progressBarWidth {
+			filled = progressBarWidth
+		}
+		bar := strings.Repeat("=", filled)
+		if filled < progressBarWidth {
+			bar += ">" + strings.Repeat(" ", progressBarWidth-filled-1)
+		}
+		fmt.Fprintf(&b, "[%s] %3d%% %s/%s", bar, progressPercent(p), formatSize(p.Done), formatSize(p.Total))
+	} else {
+		b.WriteString(formatSize(p.Done))
+	}
+	fmt.Fprintf(&b, " %s/s", formatSize(int64(p.Throughput)))
+	if eta, known := progressETA(p); known {
+		fmt.Fprintf(&b, " ETA %v", eta)
+	}
+	if p.Name != "" {
+		fmt.Fprintf(&b, " %s", shortName(p.Name))
+	}
+	return b.String()
+}
+
+// shortName keeps the tail of long names, without splitting a UTF-8
+// sequence.
+func shortName(name string) string {
+	if len(name) <= progressNameWidth {
+		return name
+	}
+	i := len(name) - progressNameWidth + 3
+	for i < len(name) && !utf8.RuneStart(name[i]) {
+		i++
+	}
+	return "..." + name[i:]
+}
+
+// progressPercent returns how much of the phase is done, capped at 100%
+// for files that grew while being read. p.Total must be positive.
+func progressPercent(p equalfile.Progress) int64 {
+	if p.Done >= p.Total {
+		return 100
+	}
+	return p.Done * 100 / p.Total
+}
+
+// progressLine formats p as a line of text, like:
+// hashing file 1 a/b/c: 1.0GiB of 2.5GiB (40%), 120.0MiB/s, ETA 13s
+func progressLine(p equalfile.Progress) string {
+	var b strings.Builder
+	b.WriteString(p.Phase.String())
+	if p.Name != "" {
+		fmt.Fprintf(&b, " %s", p.Name)
+	}
+	if p.Final {
+		fmt.Fprintf(&b, ": %s in %v, %s/s", formatSize(p.Done), p.Elapsed.Round(time.Second), formatSize(int64(p.Throughput)))
+		return b.String()
+	}
+	fmt.Fprintf(&b, ": %s", formatSize(p.Done))
+	if p.Total > 0 {
+		fmt.Fprintf(&b, " of %s (%d%%)", formatSize(p.Total), progressPercent(p))
+	}
+	fmt.Fprintf(&b, ", %s/s", formatSize(int64(p.Throughput)))
+	if eta, known := progressETA(p); known {
+		fmt.Fprintf(&b, ", ETA %v", eta)
+	}
+	return b.String()
+}
+
+// progressETA estimates the time left in the phase at the current throughput.
+func progressETA(p equalfile.Progress) (time.Duration, bool) {
+	if p.Total < 0 || p.Throughput <= 0 || p.Done > p.Total {
+		return 0, false
+	}
+	left := float64(p.Total-p.Done) / p.Throughput
+	return time.Duration(left * float64(time.Second)).Round(time.Second), true
+}
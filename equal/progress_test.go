@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/udhos/equalfile"
+)
+
+func TestProgressFormat(t *testing.T) {
+	longName := "/home/user/projects/equalfile/testdata/large/file.bin"
+	wideName := strings.Repeat("é", 20) // 40 bytes, cut must not split a rune
+
+	table := []struct {
+		p    equalfile.Progress
+		bar  string
+		line string
+		eta  string // empty if unknown
+	}{
+		{
+			p:    equalfile.Progress{Phase: equalfile.PhaseHash1, Name: "a", Done: 1536, Total: -1, Throughput: 1024},
+			bar:  "hashing file 1 1.5KiB 1.0KiB/s a",
+			line: "hashing file 1 a: 1.5KiB, 1.0KiB/s",
+		},
+		{
+			p:    equalfile.Progress{Phase: equalfile.PhaseCompare, Done: 0, Total: 0},
+			bar:  "comparing      0B 0B/s",
+			line: "comparing: 0B, 0B/s",
+		},
+		{
+			p:    equalfile.Progress{Phase: equalfile.PhaseCompare, Done: 0, Total: 0, Throughput: 10},
+			bar:  "comparing      0B 10B/s ETA 0s",
+			line: "comparing: 0B, 10B/s, ETA 0s",
+			eta:  "0s",
+		},
+		{
+			p:    equalfile.Progress{Phase: equalfile.PhaseHash2, Done: 40, Total: 100, Throughput: 10},
+			bar:  "hashing file 2 [=========>              ]  40% 40B/100B 10B/s ETA 6s",
+			line: "hashing file 2: 40B of 100B (40%), 10B/s, ETA 6s",
+			eta:  "6s",
+		},
+		{
+			p:    equalfile.Progress{Phase: equalfile.PhaseCompare, Done: 0, Total: 100},
+			bar:  "comparing      [>                       ]   0% 0B/100B 0B/s",
+			line: "comparing: 0B of 100B (0%), 0B/s",
+		},
+		{
+			p:    equalfile.Progress{Phase: equalfile.PhaseCompare, Done: 0, Total: 1000, Throughput: 3},
+			bar:  "comparing      [>                       ]   0% 0B/1000B 3B/s ETA 5m33s",
+			line: "comparing: 0B of 1000B (0%), 3B/s, ETA 5m33s",
+			eta:  "5m33s",
+		},
+		{
+			// file grew while being read
+			p:    equalfile.Progress{Phase: equalfile.PhaseCompare, Done: 150, Total: 100, Throughput: 10},
+			bar:  "comparing      [========================] 100% 150B/100B 10B/s",
+			line: "comparing: 150B of 100B (100%), 10B/s",
+		},
+		{
+			p:    equalfile.Progress{Phase: equalfile.PhaseHash2, Name: "a", Done: 2048, Total: 2048, Elapsed: 1500 * time.Millisecond, Throughput: 1365, Final: true},
+			bar:  "hashing file 2 [========================] 100% 2.0KiB/2.0KiB 1.3KiB/s ETA 0s a",
+			line: "hashing file 2 a: 2.0KiB in 2s, 1.3KiB/s",
+			eta:  "0s",
+		},
+		{
+			p:    equalfile.Progress{Phase: equalfile.PhaseHash1, Name: longName, Total: -1},
+			bar:  "hashing file 1 0B 0B/s ...lfile/testdata/large/file.bin",
+			line: "hashing file 1 " + longName + ": 0B, 0B/s",
+		},
+		{
+			p:    equalfile.Progress{Phase: equalfile.PhaseHash1, Name: wideName, Total: -1},
+			bar:  "hashing file 1 0B 0B/s ..." + strings.Repeat("é", 14),
+			line: "hashing file 1 " + wideName + ": 0B, 0B/s",
+		},
+	}
+
+	for _, x := range table {
+		if got := progressBar(x.p); got != x.bar {
+			t.Errorf("progressBar(%+v):\n got %q\nwant %q", x.p, got, x.bar)
+		}
+		if got := progressLine(x.p); got != x.line {
+			t.Errorf("progressLine(%+v):\n got %q\nwant %q", x.p, got, x.line)
+		}
+		var eta string
+		if d, known := progressETA(x.p); known {
+			eta = d.String()
+		}
+		if eta != x.eta {
+			t.Errorf("progressETA(%+v): got %q expected %q", x.p, eta, x.eta)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sizeSuffixes follows GNU coreutils: K, M, G... are powers of 1024,
+// KB, MB, GB... are powers of 1000. KiB, MiB, GiB... are accepted as
+// explicit aliases for the binary units.
+var sizeSuffixes = []struct {
+	suffix string
+	mult   int64
+}{
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+	{"TiB", 1 << 40},
+	{"PiB", 1 << 50},
+	{"KB", 1000},
+	{"MB", 1000 * 1000},
+	{"GB", 1000 * 1000 * 1000},
+	{"TB", 1000 * 1000 * 1000 * 1000},
+	{"PB", 1000 * 1000 * 1000 * 1000 * 1000},
+	{"K", 1 << 10},
+	{"M", 1 << 20},
+	{"G", 1 << 30},
+	{"T", 1 << 40},
+	{"P", 1 << 50},
+	{"B", 1},
+}
+
+// parseSize parses a byte count like "4096", "64K" or "10G".
+func parseSize(s string) (int64, error) {
+	str := strings.TrimSpace(s)
+	mult := int64(1)
+	for _, u := range sizeSuffixes {
+		if strings.HasSuffix(strings.ToUpper(str), strings.ToUpper(u.suffix)) {
+			str = strings.TrimSpace(str[:len(str)-len(u.suffix)])
+			mult = u.mult
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("negative size: %d", n)
+	}
+	if mult > 1 && n > (1<<63-1)/mult {
+		return 0, fmt.Errorf("size overflow: %s", s)
+	}
+
+	return n * mult, nil
+}
+
+// parseRate parses a rate in bytes per second like "50M" or "50M/s".
+func parseRate(s string) (int64, error) {
+	return parseSize(strings.TrimSuffix(strings.TrimSpace(s), "/s"))
+}
+
+// formatSize formats a byte count for humans, like "1.5GiB".
+func formatSize(n int64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%dB", n)
+	}
+	v := float64(n) / 1024
+	i := 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f%ciB", v, units[i])
+}
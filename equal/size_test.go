@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	good := []struct {
+		in  string
+		out int64
+	}{
+		{"0", 0},
+		{"4096", 4096},
+		{" 10 ", 10},
+		{"10B", 10},
+		{"64K", 64 << 10},
+		{"64k", 64 << 10},
+		{"1M", 1 << 20},
+		{"10G", 10 << 30},
+		{"2T", 2 << 40},
+		{"1P", 1 << 50},
+		{"1KB", 1000},
+		{"3MB", 3000000},
+		{"1GB", 1000000000},
+		{"1KiB", 1 << 10},
+		{"5 MiB", 5 << 20},
+		{"1gib", 1 << 30},
+	}
+	for _, g := range good {
+		n, err := parseSize(g.in)
+		if err != nil || n != g.out {
+			t.Errorf("parseSize(%q): got %d %v expected %d", g.in, n, err, g.out)
+		}
+	}
+
+	bad := []string{"", "K", "-1", "-1K", "1.5M", "abc", "1X", "9000000P", "9223372036854775808"}
+	for _, b := range bad {
+		if n, err := parseSize(b); err == nil {
+			t.Errorf("parseSize(%q): got %d expected error", b, n)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	good := []struct {
+		in  string
+		out int64
+	}{
+		{"1000", 1000},
+		{"50M", 50 << 20},
+		{"50M/s", 50 << 20},
+		{" 1KB/s ", 1000},
+	}
+	for _, g := range good {
+		n, err := parseRate(g.in)
+		if err != nil || n != g.out {
+			t.Errorf("parseRate(%q): got %d %v expected %d", g.in, n, err, g.out)
+		}
+	}
+
+	for _, b := range []string{"", "/s", "50M/m", "-5/s"} {
+		if n, err := parseRate(b); err == nil {
+			t.Errorf("parseRate(%q): got %d expected error", b, n)
+		}
+	}
+}
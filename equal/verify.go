@@ -0,0 +1,191 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/udhos/equalfile"
+)
+
+type verifyConfig struct {
+	options  equalfile.Options
+	hashName string
+	quiet    bool
+	progress bool
+}
+
+// digestHashes guesses the algorithm of untagged manifest lines from the
+// digest size, like sha256sum and friends would by their own name.
+var digestHashes = map[int]string{
+	16: "md5",
+	20: "sha1",
+	32: "sha256",
+	64: "sha512",
+}
+
+func parseVerifyFlags(args []string) (*verifyConfig, []string) {
+	cfg := &verifyConfig{}
+
+	var bwlimit string
+
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: equal verify [flags] SHA256SUMS [...manifestN]\n")
+		fmt.Fprintf(os.Stderr, "Use '-' to read a manifest from standard input.\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&cfg.hashName, "hash", "", "hash algorithm (default: from tagged lines, or guessed from digest size): "+manifestHashNames())
+	fs.StringVar(&bwlimit, "bwlimit", "", "limit reading to this many bytes per second (accepts suffixes like 50M)")
+	fs.IntVar(&cfg.options.IOPSLimit, "iops-limit", 0, "limit reading to this many reads per second")
+	fs.BoolVar(&cfg.options.NoAtime, "noatime", false, "open files without updating access times, where permitted (Linux O_NOATIME)")
+	fs.BoolVar(&cfg.options.DropCache, "drop-cache", false, "keep compared files from filling the page cache (Linux posix_fadvise)")
+	fs.BoolVar(&cfg.progress, "progress", false, "show progress on stderr: a bar with throughput and ETA on a terminal, periodic lines otherwise")
+	fs.BoolVar(&cfg.options.Debug, "debug", envBool("DEBUG"), "enable debugging to stdout [DEBUG]")
+	fs.BoolVar(&cfg.quiet, "quiet", false, "print nothing, report result only through exit status")
+	fs.Parse(args)
+
+	if bwlimit != "" {
+		var errConv error
+		if cfg.options.BandwidthLimit, errConv = parseRate(bwlimit); errConv != nil {
+			fmt.Fprintf(os.Stderr, "equal: bad bandwidth limit [%s]: %v\n", bwlimit, errConv)
+			os.Exit(exitTrouble)
+		}
+	}
+	shareLimits(&cfg.options)
+
+	if cfg.hashName != "" {
+		if errHash := checkManifestHash(cfg.hashName); errHash != nil {
+			fmt.Fprintf(os.Stderr, "equal: %v\n", errHash)
+			os.Exit(exitTrouble)
+		}
+	}
+
+	if cfg.quiet {
+		cfg.options.Debug = false
+		cfg.progress = false
+	}
+	if cfg.progress {
+		enableProgress(&cfg.options)
+	}
+
+	manifests := fs.Args()
+	if len(manifests) < 1 {
+		fs.Usage()
+		os.Exit(exitTrouble)
+	}
+
+	return cfg, manifests
+}
+
+// verify implements the "equal verify" subcommand, similar to "sha256sum -c".
+func verify(args []string) int {
+	cfg, manifests := parseVerifyFlags(args)
+
+	cmps := map[string]*equalfile.Cmp{} // share hash cache per algorithm
+
+	var failed, unreadable, malformed, badManifests int
+
+	for _, m := range manifests {
+		entries, err := readManifest(m)
+		if formatErr, isFormatErr := err.(*equalfile.ChecksumFormatError); isFormatErr {
+			for _, l := range formatErr.Lines {
+				fmt.Fprintf(os.Stderr, "equal: %s: %v\n", m, l)
+			}
+			malformed += len(formatErr.Lines)
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "equal: %s: %v\n", m, err)
+			badManifests++
+		}
+
+		for _, e := range entries {
+			name, errAlgo := entryHash(cfg, e)
+			if errAlgo != nil {
+				fmt.Fprintf(os.Stderr, "equal: %s: %s: %v\n", m, e.Path, errAlgo)
+				malformed++
+				continue
+			}
+
+			cmp, found := cmps[name]
+			if !found {
+				h, _, _ := newHash(name)
+				cmp = equalfile.NewMultiple(nil, cfg.options, h, false)
+				cmps[name] = cmp
+			}
+
+			ok, errVerify := cmp.VerifyChecksum(e.Path, e.Sum)
+			switch {
+			case errVerify != nil:
+				fmt.Fprintf(os.Stderr, "equal: %v\n", errVerify)
+				cfg.printf("%s: FAILED open or read\n", e.Path)
+				unreadable++
+			case !ok:
+				cfg.printf("%s: FAILED\n", e.Path)
+				failed++
+			default:
+				cfg.printf("%s: OK\n", e.Path)
+			}
+		}
+	}
+
+	if malformed > 0 {
+		fmt.Fprintf(os.Stderr, "equal: WARNING: %d line(s) improperly formatted\n", malformed)
+	}
+	if unreadable > 0 {
+		fmt.Fprintf(os.Stderr, "equal: WARNING: %d listed file(s) could not be read\n", unreadable)
+	}
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "equal: WARNING: %d computed checksum(s) did NOT match\n", failed)
+	}
+
+	switch {
+	case malformed > 0 || unreadable > 0 || badManifests > 0:
+		return exitTrouble
+	case failed > 0:
+		return exitDiffer
+	}
+	return exitEqual
+}
+
+func readManifest(path string) ([]equalfile.ChecksumEntry, error) {
+	var r io.Reader = os.Stdin
+	if path != stdin {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	return equalfile.ParseChecksums(r)
+}
+
+// entryHash picks the hash algorithm for a manifest entry.
+func entryHash(cfg *verifyConfig, e equalfile.ChecksumEntry) (string, error) {
+	if cfg.hashName != "" {
+		if err := checkManifestHash(cfg.hashName); err != nil {
+			return "", err
+		}
+		return cfg.hashName, nil
+	}
+	if e.Algo != "" {
+		name := strings.ToLower(strings.Replace(e.Algo, "-", "", -1))
+		if checkManifestHash(name) != nil {
+			return "", fmt.Errorf("unsupported hash %s", e.Algo)
+		}
+		return name, nil
+	}
+	name, found := digestHashes[len(e.Sum)]
+	if !found {
+		return "", fmt.Errorf("can't guess hash from %d-byte digest, use --hash", len(e.Sum))
+	}
+	return name, nil
+}
+
+func (cfg *verifyConfig) printf(format string, v ...interface{}) {
+	if !cfg.quiet {
+		fmt.Printf(format, v...)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/udhos/equalfile"
+)
+
+func TestEntryHash(t *testing.T) {
+	sum32 := make([]byte, 32)
+	table := []struct {
+		hashName string
+		entry    equalfile.ChecksumEntry
+		want     string // empty if an error is expected
+	}{
+		{"", equalfile.ChecksumEntry{Sum: sum32}, "sha256"},
+		{"", equalfile.ChecksumEntry{Sum: make([]byte, 16)}, "md5"},
+		{"", equalfile.ChecksumEntry{Sum: make([]byte, 8)}, ""},
+		{"", equalfile.ChecksumEntry{Sum: sum32, Algo: "SHA-512"}, "sha512"},
+		{"", equalfile.ChecksumEntry{Sum: sum32, Algo: "BLAKE2"}, ""},
+		{"", equalfile.ChecksumEntry{Sum: make([]byte, 8), Algo: "MAPHASH"}, ""},
+		{"crc64", equalfile.ChecksumEntry{Sum: sum32}, "crc64"},
+		{"maphash", equalfile.ChecksumEntry{Sum: make([]byte, 8)}, ""},
+	}
+	for _, x := range table {
+		cfg := &verifyConfig{hashName: x.hashName}
+		name, err := entryHash(cfg, x.entry)
+		if x.want == "" {
+			if err == nil {
+				t.Errorf("entryHash(%q, %+v): got %s expected error", x.hashName, x.entry, name)
+			}
+			continue
+		}
+		if err != nil || name != x.want {
+			t.Errorf("entryHash(%q, %+v): got %s %v expected %s", x.hashName, x.entry, name, err, x.want)
+		}
+	}
+}
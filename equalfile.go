@@ -7,6 +7,7 @@ import (
 	"hash"
 	"io"
 	"os"
+	"time"
 )
 
 // Only the first 10^10 bytes of io.Reader are compared.  Ignored when using io.LimitedReader
@@ -21,8 +22,67 @@ type Options struct {
 	// reader.  If left unset, will default to 1OGBytes. Ignored when
 	// CompareReader() is given one or more io.LimitedReader.
 	MaxSize int64
+
+	// Symlinks controls how CompareFile handles symbolic links.
+	// Defaults to SymlinkFollow.
+	Symlinks SymlinkMode
+
+	// Metadata selects attributes CompareFile checks besides content.
+	// Any difference makes CompareFile return false, and is reported in
+	// Result.MetadataDiff.
+	Metadata         Metadata
+	ModTimeTolerance time.Duration // allowed modification time difference
+
+	// Sparse makes CompareFile skip holes in regular files, reading only
+	// regions where at least one file has data (Linux SEEK_DATA/SEEK_HOLE).
+	// Falls back to reading everything where holes can't be detected.
+	Sparse bool
+
+	// SortMemory bounds the memory CompareLineSet uses for sorting lines
+	// of each input, beyond which sorted runs spill to temporary files.
+	// If left unset, will default to 64MBytes.
+	SortMemory int64
+
+	// HTTPShallow lets CompareURL and CompareURLs trust matching ETag or
+	// Last-Modified headers instead of downloading the content.
+	HTTPShallow bool
+
+	// Storage provides the contents for path-based comparisons.
+	// If left unset, the local filesystem is used.
+	Storage Storage
+
+	// BandwidthLimit caps reading, including hashing in multiple mode, to
+	// this many bytes per second. IOPSLimit caps the number of reads per
+	// second. Zero means unlimited. Limits apply to the Cmp created with
+	// these options; see Limiter to share them among several Cmp.
+	BandwidthLimit int64
+	IOPSLimit      int
+
+	// Limiter, if set, paces reads instead of BandwidthLimit and IOPSLimit.
+	Limiter *Limiter
+
+	// Progress, if set, is called periodically while hashing or comparing,
+	// every ProgressInterval (default 1 second), and once more at the end
+	// of each phase. It runs in the comparing goroutine, and should return
+	// quickly.
+	Progress         func(Progress)
+	ProgressInterval time.Duration
+
+	// NoAtime opens files with O_NOATIME where permitted (Linux, for the
+	// file owner or with CAP_FOWNER), so reading does not update access
+	// times. DropCache advises the kernel that files are read sequentially
+	// and that pages already read won't be needed again, so comparing huge
+	// files does not evict hot data from the page cache (Linux
+	// posix_fadvise, on 64-bit platforms only). Both apply to files opened
+	// from the local filesystem, for comparison as well as multiple mode
+	// hashing. Elsewhere, they have no effect.
+	NoAtime   bool
+	DropCache bool
 }
 
+// Cmp compares contents. It keeps state between comparisons, like the
+// multiple mode hash table and LastResult, so it is not safe for
+// concurrent use.
 type Cmp struct {
 	Opt Options
 
@@ -36,15 +96,56 @@ type Cmp struct {
 	hashTable        map[string]hashSum
 
 	buf []byte
+
+	last Result
+
+	throttle Limiter // for Opt.BandwidthLimit and Opt.IOPSLimit
+
+	prog progress
+
+	adviseWarned bool // a posix_fadvise error was reported
+}
+
+// Result describes the outcome of the most recent comparison performed by Cmp.
+type Result struct {
+	Equal  bool
+	Reason string // one of the Reason constants
+	Offset int64  // offset of the first differing byte, or -1 if none was found
+	Size1  int64  // size reported by stat, or -1 if unknown
+	Size2  int64
+	Hash1  []byte // digests used in multiple mode, or nil
+	Hash2  []byte
+
+	// ContentEqual is the content verdict. It differs from Equal only
+	// when Options.Metadata finds differences, listed in MetadataDiff.
+	ContentEqual bool
+	MetadataDiff Metadata
 }
 
+// Reasons reported in Result.
+const (
+	ReasonSameFile        = "same file"        // filesystem reported same file
+	ReasonSizeMismatch    = "size mismatch"    // regular files with distinct sizes
+	ReasonHashMismatch    = "hash mismatch"    // multiple mode hashes differ
+	ReasonHashMatch       = "hash match"       // multiple mode hashes match, bytes not compared
+	ReasonContentMatch    = "content match"    // byte-by-byte comparison found no difference
+	ReasonContentMismatch = "content mismatch" // byte-by-byte comparison found a difference
+	ReasonLengthMismatch  = "length mismatch"  // one input ended before the other
+	ReasonMaxSize         = "max size reached" // inputs matched up to MaxSize
+	ReasonError           = "error"
+)
+
 type hashSum struct {
 	result []byte
 	err    error
 }
 
 // NewMultiple creates Cmp for multiple comparison mode.
+// If h was created by NewFastHash, compareOnMatch is forced to true.
 func NewMultiple(buf []byte, options Options, h hash.Hash, compareOnMatch bool) *Cmp {
+	if isFastHash(h) {
+		compareOnMatch = true
+	}
 	c := &Cmp{
 		Opt:              options,
 		hashType:         h,
@@ -64,28 +165,45 @@ func New(buf []byte, options Options) *Cmp {
 	return NewMultiple(buf, options, nil, true)
 }
 
-func (c *Cmp) getHash(path string, maxSize int64) ([]byte, error) {
-	h, found := c.hashTable[path]
+func (c *Cmp) getHash(path string, maxSize int64, phase Phase) ([]byte, error) {
+	key := c.hashKey(path)
+	if _, found := c.hashTable[key]; !found {
+		if sum, ok := c.precomputedHash(path); ok {
+			return c.newHash(key, sum, nil)
+		}
+	}
+	return c.getReaderHash(key, path, func() (io.ReadCloser, error) { return c.storage().Open(path) }, maxSize, phase)
+}
+
+// getReaderHash returns the hash for key, calling open to read the
+// content only if key is not found in the hash table. Reading is reported
+// to Opt.Progress as phase of name.
+func (c *Cmp) getReaderHash(key, name string, open OpenFunc, maxSize int64, phase Phase) ([]byte, error) {
+	h, found := c.hashTable[key]
 	if found {
 		return h.result, h.err
 	}
 
-	f, openErr := os.Open(path)
+	f, openErr := open()
 	if openErr != nil {
 		return nil, openErr
 	}
 	defer f.Close()
 
+	c.startPhase(phase, name, c.hashTotal(f, maxSize))
+
 	sum := make([]byte, c.hashType.Size())
 	c.hashType.Reset()
-	n, copyErr := io.CopyN(c.hashType, f, maxSize)
+	n, copyErr := io.CopyN(c.hashType, &progressReader{c: c, r: &cmpReader{c: c, r: f}}, maxSize)
 	copy(sum, c.hashType.Sum(nil))
 
+	c.endPhase()
+
 	if copyErr == io.EOF && n < maxSize {
 		copyErr = nil
 	}
 
-	return c.newHash(path, sum, copyErr)
+	return c.newHash(key, sum, copyErr)
 }
 
 func (c *Cmp) newHash(path string, sum []byte, e error) ([]byte, error) {
@@ -101,45 +219,85 @@ func (c *Cmp) multipleMode() bool {
 	return c.hashType != nil
 }
 
+// LastResult reports details about the most recent comparison.
+func (c *Cmp) LastResult() Result {
+	return c.last
+}
+
+func (c *Cmp) resetResult(size1, size2 int64) {
+	c.last = Result{Offset: -1, Size1: size1, Size2: size2}
+}
+
+func (c *Cmp) result(equal bool, reason string) bool {
+	c.last.Equal = equal
+	c.last.ContentEqual = equal
+	c.last.Reason = reason
+	return equal
+}
+
+func (c *Cmp) resultErr(err error) (bool, error) {
+	c.result(false, ReasonError)
+	return false, err
+}
+
 // CompareFile verifies that files with names path1, path2 have same contents.
+// If Opt.Metadata is set, the selected attributes must match as well.
+// Details about the comparison are available from LastResult.
 func (c *Cmp) CompareFile(path1, path2 string) (bool, error) {
+	equal, err := c.compareFile(path1, path2)
+	if err != nil || c.Opt.Metadata == 0 {
+		return equal, err
+	}
+
+	if errMeta := c.compareMetadata(path1, path2); errMeta != nil {
+		return c.resultErr(errMeta)
+	}
+	if c.last.MetadataDiff != 0 {
+		c.last.Equal = false
+		return false, nil
+	}
+
+	return equal, nil
+}
+
+func (c *Cmp) compareFile(path1, path2 string) (bool, error) {
+
+	c.resetResult(-1, -1)
 
 	if c.Opt.MaxSize < 0 {
-		return false, fmt.Errorf("negative MaxSize")
+		return c.resultErr(fmt.Errorf("negative MaxSize"))
+	}
+
+	if done, equal, err := c.compareSymlinks(path1, path2); done {
+		return equal, err
 	}
 
-	r1, openErr1 := os.Open(path1)
+	r1, info1, openErr1 := c.openStat(path1)
 	if openErr1 != nil {
-		return false, openErr1
+		return c.resultErr(openErr1)
 	}
 	defer r1.Close()
-	info1, statErr1 := r1.Stat()
-	if statErr1 != nil {
-		return false, statErr1
-	}
 
-	r2, openErr2 := os.Open(path2)
+	r2, info2, openErr2 := c.openStat(path2)
 	if openErr2 != nil {
-		return false, openErr2
+		return c.resultErr(openErr2)
 	}
 	defer r2.Close()
-	info2, statErr2 := r2.Stat()
-	if statErr2 != nil {
-		return false, statErr2
-	}
+
+	c.resetResult(info1.Size(), info2.Size())
 
 	if !c.Opt.ForceFileRead {
 		// shortcut: ask the filesystem: are these files the same? (link, pathname, etc)
-		if os.SameFile(info1, info2) {
+		if c.sameFile(info1, info2) {
 			c.debugf("CompareFile(%s,%s): os reported same file\n", path1, path2)
-			return true, nil
+			return c.result(true, ReasonSameFile), nil
 		}
 	}
 
 	if info1.Mode().IsRegular() && info2.Mode().IsRegular() {
 		if info1.Size() != info2.Size() {
 			c.debugf("CompareFile(%s,%s): distinct file sizes\n", path1, path2)
-			return false, nil
+			return c.result(false, ReasonSizeMismatch), nil
 		}
 	}
 
@@ -161,20 +319,22 @@ func (c *Cmp) CompareFile(path1, path2 string) (bool, error) {
 	}
 
 	if c.multipleMode() {
-		h1, err1 := c.getHash(path1, maxSize)
+		h1, err1 := c.getHash(path1, maxSize, PhaseHash1)
 		if err1 != nil {
-			return false, err1
+			return c.resultErr(err1)
 		}
-		h2, err2 := c.getHash(path2, maxSize)
+		h2, err2 := c.getHash(path2, maxSize, PhaseHash2)
 		if err2 != nil {
-			return false, err2
+			return c.resultErr(err2)
 		}
+		c.last.Hash1 = h1
+		c.last.Hash2 = h2
 		if !bytes.Equal(h1, h2) {
-			return false, nil // hashes mismatch
+			return c.result(false, ReasonHashMismatch), nil // hashes mismatch
 		}
 		// hashes match
 		if !c.hashMatchCompare {
-			return true, nil // accept hash match without byte-by-byte comparison
+			return c.result(true, ReasonHashMatch), nil // accept hash match without byte-by-byte comparison
 		}
 		// do byte-by-byte comparison
 		c.debugf("CompareFile(%s,%s): hash match, will compare bytes\n", path1, path2)
@@ -185,6 +345,15 @@ func (c *Cmp) CompareFile(path1, path2 string) (bool, error) {
 	// input amount exceeding MaxSize, so we can't use LimitedReader.
 	c.resetDebugging()
 
+	f1, isFile1 := osFile(r1)
+	f2, isFile2 := osFile(r2)
+	if c.Opt.Sparse && isFile1 && isFile2 && info1.Mode().IsRegular() && info2.Mode().IsRegular() {
+		if handled, eq, err := c.compareSparse(f1, f2, info1.Size(), maxSize); handled {
+			c.printDebugCompareReader()
+			return eq, err
+		}
+	}
+
 	eq, err := c.compareReader(r1, r2, maxSize)
 
 	c.printDebugCompareReader()
@@ -193,8 +362,13 @@ func (c *Cmp) CompareFile(path1, path2 string) (bool, error) {
 }
 
 func (c *Cmp) read(r io.Reader, buf []byte) (int, error) {
+	limiter := c.limiter()
+	limiter.wait()
+
 	n, err := r.Read(buf)
 
+	limiter.charge(n)
+
 	if err == io.EOF {
 		c.debugf("read: EOF found\n")
 	}
@@ -213,13 +387,26 @@ func (c *Cmp) read(r io.Reader, buf []byte) (int, error) {
 	return n, err
 }
 
+// cmpReader reads through Cmp.read, so that reading is accounted for
+// and throttled like in the comparison methods.
+type cmpReader struct {
+	c *Cmp
+	r io.Reader
+}
+
+func (r *cmpReader) Read(p []byte) (int, error) {
+	return r.c.read(r.r, p)
+}
+
 // CompareReader verifies that two readers provide same content.
 //
 // Reading more than MaxSize will return an error (along with the comparison
 // value up to MaxSize bytes), unless one or both Readers are LimitedReaders,
 // in which case MaxSize is ignored.
+// Details about the comparison are available from LastResult.
 func (c *Cmp) CompareReader(r1, r2 io.Reader) (bool, error) {
 
+	c.resetResult(-1, -1)
 	c.resetDebugging()
 
 	equal, err := c.compareReader(r1, r2, c.Opt.MaxSize)
@@ -229,6 +416,79 @@ func (c *Cmp) CompareReader(r1, r2 io.Reader) (bool, error) {
 	return equal, err
 }
 
+// CompareReaderFile verifies that reader r provides the same content as
+// file path. It is useful for comparing standard input or an already open
+// file against a path.
+//
+// If r is an *os.File positioned within a regular file, the remaining size
+// is compared against the size of path first, like CompareFile does.
+// Otherwise r is read as a stream. The reader side is never hashed, even in
+// multiple mode, since it cannot be read again.
+func (c *Cmp) CompareReaderFile(r io.Reader, path string) (bool, error) {
+
+	c.resetResult(-1, -1)
+
+	if c.Opt.MaxSize < 0 {
+		return c.resultErr(fmt.Errorf("negative MaxSize"))
+	}
+
+	f, info, openErr := c.openStat(path)
+	if openErr != nil {
+		return c.resultErr(openErr)
+	}
+	defer f.Close()
+
+	size := readerSize(r)
+
+	c.resetResult(size, info.Size())
+
+	if size >= 0 && info.Mode().IsRegular() {
+		if size != info.Size() {
+			c.debugf("CompareReaderFile(%s): distinct file sizes\n", path)
+			return c.result(false, ReasonSizeMismatch), nil
+		}
+	}
+
+	maxSize := c.Opt.MaxSize
+	if maxSize == 0 {
+		// The stream side may be longer than the file, so the file size
+		// alone is not a safe limit.
+		maxSize = info.Size()
+		if size < 0 && maxSize < defaultMaxSize {
+			maxSize = defaultMaxSize
+		}
+		if maxSize == 0 { // possible non-regular file
+			maxSize = defaultMaxSize
+		}
+	}
+
+	c.resetDebugging()
+
+	eq, err := c.compareReader(r, f, maxSize)
+
+	c.printDebugCompareReader()
+
+	return eq, err
+}
+
+// readerSize returns the number of bytes left in r if r is an *os.File
+// for a regular file, or -1 otherwise.
+func readerSize(r io.Reader) int64 {
+	f, isFile := r.(*os.File)
+	if !isFile {
+		return -1
+	}
+	info, statErr := f.Stat()
+	if statErr != nil || !info.Mode().IsRegular() {
+		return -1
+	}
+	pos, seekErr := f.Seek(0, io.SeekCurrent)
+	if seekErr != nil || pos > info.Size() {
+		return -1
+	}
+	return info.Size() - pos
+}
+
 func (c *Cmp) resetDebugging() {
 	if c.Opt.Debug {
 		c.readCount = 0
@@ -288,7 +548,7 @@ func (c *Cmp) compareReader(r1, r2 io.Reader, maxSize int64) (bool, error) {
 		}
 
 		if maxSize < 1 {
-			return false, fmt.Errorf("nonpositive max size")
+			return c.resultErr(fmt.Errorf("nonpositive max size"))
 		}
 
 		lr1 = io.LimitReader(r1, maxSize)
@@ -301,18 +561,22 @@ func (c *Cmp) compareReader(r1, r2 io.Reader, maxSize int64) (bool, error) {
 
 	size := len(buf) / 2
 	if size < 1 {
-		return false, fmt.Errorf("insufficient buffer size")
+		return c.resultErr(fmt.Errorf("insufficient buffer size"))
 	}
 
+	c.startPhase(PhaseCompare, "", c.compareTotal(r1, r2))
+	defer c.endPhase()
+
 	buf1 := buf[:size]
 	buf2 := buf[size : 2*size] // must force same size as buf1
 
 	if len(buf1) != len(buf2) {
-		return false, fmt.Errorf("buffer size mismatch buf1=%d buf2=%d", len(buf1), len(buf2))
+		return c.resultErr(fmt.Errorf("buffer size mismatch buf1=%d buf2=%d", len(buf1), len(buf2)))
 	}
 
 	eof1 := false
 	eof2 := false
+	var offset int64 // bytes found equal so far
 
 	for !eof1 && !eof2 {
 		n1, err1 := c.read(lr1, buf1)
@@ -321,7 +585,7 @@ func (c *Cmp) compareReader(r1, r2 io.Reader, maxSize int64) (bool, error) {
 			eof1 = true
 		case nil:
 		default:
-			return false, err1
+			return c.resultErr(err1)
 		}
 
 		n2, err2 := c.read(lr2, buf2)
@@ -330,7 +594,7 @@ func (c *Cmp) compareReader(r1, r2 io.Reader, maxSize int64) (bool, error) {
 			eof2 = true
 		case nil:
 		default:
-			return false, err2
+			return c.resultErr(err2)
 		}
 
 		switch {
@@ -341,7 +605,7 @@ func (c *Cmp) compareReader(r1, r2 io.Reader, maxSize int64) (bool, error) {
 				eof1 = true
 			case nil:
 			default:
-				return false, errPart
+				return c.resultErr(errPart)
 			}
 			n1 = n
 		case n2 < n1:
@@ -351,25 +615,46 @@ func (c *Cmp) compareReader(r1, r2 io.Reader, maxSize int64) (bool, error) {
 				eof2 = true
 			case nil:
 			default:
-				return false, errPart
+				return c.resultErr(errPart)
 			}
 			n2 = n
 		}
 
 		if n1 != n2 {
 			c.debugf("compareReader: distinct buffer sizes\n")
-			return false, nil
+			i := mismatchIndex(buf1[:n1], buf2[:n2])
+			c.last.Offset = offset + int64(i)
+			if i < n1 && i < n2 {
+				return c.result(false, ReasonContentMismatch), nil
+			}
+			return c.result(false, ReasonLengthMismatch), nil
 		}
 
 		if !bytes.Equal(buf1[:n1], buf2[:n2]) {
 			c.debugf("compareReader: found byte mismatch\n")
-			return false, nil
+			c.last.Offset = offset + int64(mismatchIndex(buf1[:n1], buf2[:n2]))
+			return c.result(false, ReasonContentMismatch), nil
 		}
+
+		offset += int64(n1)
+		c.progressUpdate(offset)
+	}
+
+	// A reader may return its last bytes along with io.EOF, while the
+	// other reports EOF only on the next read.
+	if !eof1 {
+		n, _ := readPartial(c, lr1, buf1[:1], 0, 1)
+		eof1 = n == 0
+	}
+	if !eof2 {
+		n, _ := readPartial(c, lr2, buf2[:1], 0, 1)
+		eof2 = n == 0
 	}
 
 	if !eof1 || !eof2 {
 		c.debugf("compareReader: EOF for only one input\n")
-		return false, nil
+		c.last.Offset = offset
+		return c.result(false, ReasonLengthMismatch), nil
 	}
 
 	// Check the EOF status of the original readers. If neither was a
@@ -384,22 +669,45 @@ func (c *Cmp) compareReader(r1, r2 io.Reader, maxSize int64) (bool, error) {
 		eof2 = postEOFCheck(c, lr2, buf2[:1])
 		switch {
 		case eof1 && eof2:
-			return true, nil
+			return c.result(true, ReasonContentMatch), nil
 		default:
 			c.debugf("compareReader: partial match, but max size exceeded\n")
-			return true, fmt.Errorf("max read size reached")
+			return c.result(true, ReasonMaxSize), fmt.Errorf("max read size reached")
 		}
 	}
 	// Return false if only one reader is a LimitedReader, and the other
 	// still has data to be read.  Else return true.
 	if checkAfterEOF1 {
-		return postEOFCheck(c, lr1, buf1[:1]), nil
+		return c.afterEOFResult(postEOFCheck(c, lr1, buf1[:1]), offset), nil
 	}
 	if checkAfterEOF2 {
-		return postEOFCheck(c, lr2, buf2[:1]), nil
+		return c.afterEOFResult(postEOFCheck(c, lr2, buf2[:1]), offset), nil
+	}
+
+	return c.result(true, ReasonContentMatch), nil
+}
+
+func (c *Cmp) afterEOFResult(eof bool, offset int64) bool {
+	if !eof {
+		c.last.Offset = offset
+		return c.result(false, ReasonLengthMismatch)
 	}
+	return c.result(true, ReasonContentMatch)
+}
 
-	return true, nil
+// mismatchIndex returns the index of the first differing byte in b1 and b2,
+// or the length of the shorter slice when one is a prefix of the other.
+func mismatchIndex(b1, b2 []byte) int {
+	n := len(b1)
+	if len(b2) < n {
+		n = len(b2)
+	}
+	for i := 0; i < n; i++ {
+		if b1[i] != b2[i] {
+			return i
+		}
+	}
+	return n
 }
 
 // postEOFCheck returns false if there is more data in a LimitedReader after
@@ -4,7 +4,11 @@ package equalfile
 
 import (
 	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestCompareLimitBroken(t *testing.T) {
@@ -82,3 +86,125 @@ func batchMultiple(t *testing.T, limit int64, buf []byte) {
 	compare(t, c, "/dev/urandom", "/dev/urandom", expectUnequal)
 	compare(t, c, "/dev/zero", "/dev/zero", expectError)
 }
+
+func TestSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "equalfile_test_symlinks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file1 := filepath.Join(dir, "file1")
+	file2 := filepath.Join(dir, "file2")
+	link1 := filepath.Join(dir, "link1")   // -> file1
+	link1b := filepath.Join(dir, "link1b") // -> file1
+	link2 := filepath.Join(dir, "link2")   // -> file2
+	for _, f := range []string{file1, file2} {
+		if err := ioutil.WriteFile(f, []byte("same content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{link1: "file1", link1b: "file1", link2: "file2"} {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		mode         SymlinkMode
+		path1, path2 string
+		want         bool
+		reason       string
+	}{
+		{mode: SymlinkFollow, path1: link1, path2: file1, want: true, reason: ReasonSameFile},
+		{mode: SymlinkFollow, path1: link1, path2: link2, want: true, reason: ReasonContentMatch},
+		{mode: SymlinkCompareTarget, path1: link1, path2: file1, want: false, reason: ReasonTypeMismatch},
+		{mode: SymlinkCompareTarget, path1: link1, path2: link1b, want: true, reason: ReasonTargetMatch},
+		{mode: SymlinkCompareTarget, path1: link1, path2: link2, want: false, reason: ReasonTargetMismatch},
+		{mode: SymlinkCompareTarget, path1: file1, path2: file2, want: true, reason: ReasonContentMatch},
+		{mode: SymlinkTypeMismatch, path1: file2, path2: link1, want: false, reason: ReasonTypeMismatch},
+		{mode: SymlinkTypeMismatch, path1: link1, path2: link2, want: true, reason: ReasonContentMatch},
+	}
+
+	for _, v := range tests {
+		c := New(nil, Options{Symlinks: v.mode})
+		eq, err := c.CompareFile(v.path1, v.path2)
+		r := c.LastResult()
+		if eq != v.want || err != nil || r.Reason != v.reason {
+			t.Errorf("CompareFile(%s,%s) mode=%d: got %v %v %q expected %v %q",
+				filepath.Base(v.path1), filepath.Base(v.path2), v.mode, eq, err, r.Reason, v.want, v.reason)
+		}
+	}
+
+	// When following links, a link and its target share a hash cache entry.
+	c := NewMultiple(nil, Options{ForceFileRead: true}, sha256.New(), false)
+	compare(t, c, link1, file2, expectEqual)
+	compare(t, c, file1, link2, expectEqual)
+	if len(c.hashTable) != 2 {
+		t.Errorf("hash table should have 2 entries, got %d", len(c.hashTable))
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	pat := "equalfiles_test_metadata"
+	contents := [][]byte{[]byte("abc"), []byte("abc"), []byte("xyz")}
+	tmpFiles := makeTmpFiles(t, pat, contents)
+	defer cleanupTmpFiles(tmpFiles)
+
+	path1, path2, path3 := tmpFiles[0].Name(), tmpFiles[1].Name(), tmpFiles[2].Name()
+
+	now := time.Now()
+	for _, p := range []string{path1, path2, path3} {
+		if err := os.Chmod(p, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, now, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(path2, 0600); err != nil {
+		t.Fatal(err)
+	}
+	later := now.Add(2 * time.Second)
+	if err := os.Chtimes(path2, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		opt          Options
+		path         string
+		want         bool
+		contentEqual bool
+		diff         Metadata
+	}{
+		{opt: Options{}, path: path2, want: true, contentEqual: true},
+		{opt: Options{Metadata: MetadataMode}, path: path2, want: false, contentEqual: true, diff: MetadataMode},
+		{opt: Options{Metadata: MetadataModTime}, path: path2, want: false, contentEqual: true, diff: MetadataModTime},
+		{opt: Options{Metadata: MetadataModTime, ModTimeTolerance: 3 * time.Second}, path: path2, want: true, contentEqual: true},
+		{opt: Options{Metadata: MetadataMode | MetadataModTime | MetadataOwner}, path: path2, want: false, contentEqual: true, diff: MetadataMode | MetadataModTime},
+		{opt: Options{Metadata: MetadataMode | MetadataModTime | MetadataOwner}, path: path3, want: false, contentEqual: false},
+	}
+
+	for _, v := range tests {
+		c := New(nil, v.opt)
+		eq, err := c.CompareFile(path1, v.path)
+		r := c.LastResult()
+		if eq != v.want || err != nil || r.ContentEqual != v.contentEqual || r.MetadataDiff != v.diff {
+			t.Errorf("CompareFile metadata=%v: got %v %v content=%v diff=%v expected %v content=%v diff=%v",
+				v.opt.Metadata, eq, err, r.ContentEqual, r.MetadataDiff, v.want, v.contentEqual, v.diff)
+		}
+	}
+}
+
+func TestParseMetadata(t *testing.T) {
+	m, err := ParseMetadata("mode, mtime,owner,xattr")
+	if err != nil || m != MetadataMode|MetadataModTime|MetadataOwner|MetadataXattr {
+		t.Errorf("ParseMetadata: got %v %v", m, err)
+	}
+	if m.String() != "mode,owner,mtime,xattr" {
+		t.Errorf("Metadata.String: got %q", m.String())
+	}
+	if _, err := ParseMetadata("mode,bogus"); err == nil {
+		t.Errorf("ParseMetadata: missing expected error")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 const (
@@ -95,6 +96,23 @@ func TestReader2(t *testing.T) {
 		t.Errorf("unexpected error: %v", err)
 	}
 }
+
+func TestReaderDataWithEOF(t *testing.T) {
+	debug := os.Getenv("DEBUG") != ""
+	c := New(nil, Options{Debug: debug})
+	// DataErrReader returns the last bytes along with io.EOF
+	r1 := iotest.DataErrReader(strings.NewReader("wow"))
+	r2 := strings.NewReader("wow")
+	equal, err := c.CompareReader(r1, r2)
+	if !equal || err != nil {
+		t.Errorf("CompareReader: got %v %v expected true, reason %q", equal, err, c.LastResult().Reason)
+	}
+	equal, err = c.CompareReader(iotest.DataErrReader(strings.NewReader("wow")), strings.NewReader("wowx"))
+	if equal || err != nil || c.LastResult().Reason != ReasonLengthMismatch {
+		t.Errorf("CompareReader: got %v %v %q expected false", equal, err, c.LastResult().Reason)
+	}
+}
+
 func TestLimitedReaders(t *testing.T) {
 	debug := os.Getenv("DEBUG") != ""
 	// MaxSize should be ignored.  Set to lowest value (1) to confirm
@@ -459,3 +477,138 @@ func compareExpectErrorAndEqual(t *testing.T, c *Cmp, path1, path2 string) {
 		t.Errorf("compareExpectErrorAndEqual: unexpected unequal: CompareFile(%s,%s,%d,%d)", path1, path2, c.Opt.MaxSize, len(c.buf))
 	}
 }
+
+func TestLastResult(t *testing.T) {
+	pat := "equalfiles_test_result"
+	contents := [][]byte{[]byte("abcdef"), []byte("abcxef"), []byte("abc"), []byte("abcdef")}
+	tmpFiles := makeTmpFiles(t, pat, contents)
+	defer cleanupTmpFiles(tmpFiles)
+
+	var tests = []struct {
+		c       *Cmp
+		i1, i2  int
+		reason  string
+		offset  int64
+		hashSet bool
+	}{
+		{c: New(nil, Options{}), i1: 0, i2: 1, reason: ReasonContentMismatch, offset: 3},
+		{c: New(nil, Options{}), i1: 0, i2: 2, reason: ReasonSizeMismatch, offset: -1},
+		{c: New(nil, Options{}), i1: 0, i2: 3, reason: ReasonContentMatch, offset: -1},
+		{c: New(nil, Options{}), i1: 0, i2: 0, reason: ReasonSameFile, offset: -1},
+		{c: NewMultiple(nil, Options{}, sha256.New(), false), i1: 0, i2: 1, reason: ReasonHashMismatch, offset: -1, hashSet: true},
+		{c: NewMultiple(nil, Options{}, sha256.New(), false), i1: 0, i2: 3, reason: ReasonHashMatch, offset: -1, hashSet: true},
+		{c: NewMultiple(nil, Options{}, sha256.New(), true), i1: 0, i2: 3, reason: ReasonContentMatch, offset: -1, hashSet: true},
+	}
+
+	for _, v := range tests {
+		path1, path2 := tmpFiles[v.i1].Name(), tmpFiles[v.i2].Name()
+		if _, err := v.c.CompareFile(path1, path2); err != nil {
+			t.Errorf("CompareFile(%s,%s): unexpected error: %v", path1, path2, err)
+			continue
+		}
+		r := v.c.LastResult()
+		if r.Reason != v.reason {
+			t.Errorf("CompareFile(%s,%s): reason got %q expected %q", path1, path2, r.Reason, v.reason)
+		}
+		if r.Offset != v.offset {
+			t.Errorf("CompareFile(%s,%s): offset got %d expected %d", path1, path2, r.Offset, v.offset)
+		}
+		if r.Size1 != int64(len(contents[v.i1])) || r.Size2 != int64(len(contents[v.i2])) {
+			t.Errorf("CompareFile(%s,%s): sizes got %d,%d", path1, path2, r.Size1, r.Size2)
+		}
+		if v.hashSet != (r.Hash1 != nil && r.Hash2 != nil) {
+			t.Errorf("CompareFile(%s,%s): unexpected hashes %x,%x", path1, path2, r.Hash1, r.Hash2)
+		}
+	}
+}
+
+func TestLastResultReader(t *testing.T) {
+	var tests = []struct {
+		s1, s2  string
+		bufSize int
+		reason  string
+		offset  int64
+	}{
+		{s1: "abcdef", s2: "abcxef", bufSize: 2, reason: ReasonContentMismatch, offset: 3},
+		{s1: "abcdef", s2: "abcxef", bufSize: 100, reason: ReasonContentMismatch, offset: 3},
+		{s1: "abc", s2: "abcdef", bufSize: 2, reason: ReasonLengthMismatch, offset: 3},
+		{s1: "abcdef", s2: "abc", bufSize: 100, reason: ReasonLengthMismatch, offset: 3},
+		{s1: "abc", s2: "abc", bufSize: 2, reason: ReasonContentMatch, offset: -1},
+	}
+
+	for _, v := range tests {
+		c := New(make([]byte, v.bufSize), Options{})
+		if _, err := c.CompareReader(strings.NewReader(v.s1), strings.NewReader(v.s2)); err != nil {
+			t.Errorf("CompareReader(%q,%q): unexpected error: %v", v.s1, v.s2, err)
+			continue
+		}
+		r := c.LastResult()
+		if r.Reason != v.reason || r.Offset != v.offset {
+			t.Errorf("CompareReader(%q,%q): got reason=%q offset=%d expected reason=%q offset=%d",
+				v.s1, v.s2, r.Reason, r.Offset, v.reason, v.offset)
+		}
+	}
+}
+
+func TestCompareReaderFile(t *testing.T) {
+	pat := "equalfiles_test_readerfile"
+	contents := [][]byte{[]byte("abcdef"), []byte("xyzabcdef")}
+	tmpFiles := makeTmpFiles(t, pat, contents)
+	defer cleanupTmpFiles(tmpFiles)
+
+	path := tmpFiles[0].Name()
+
+	var tests = []struct {
+		r      io.Reader
+		want   bool
+		reason string
+	}{
+		{r: strings.NewReader("abcdef"), want: true, reason: ReasonContentMatch},
+		{r: strings.NewReader("abcdeX"), want: false, reason: ReasonContentMismatch},
+		{r: strings.NewReader("abcdefgh"), want: false, reason: ReasonLengthMismatch},
+		{r: strings.NewReader("abc"), want: false, reason: ReasonLengthMismatch},
+	}
+
+	for _, v := range tests {
+		c := New(nil, Options{})
+		eq, err := c.CompareReaderFile(v.r, path)
+		if err != nil {
+			t.Errorf("CompareReaderFile: unexpected error: %v", err)
+			continue
+		}
+		if eq != v.want || c.LastResult().Reason != v.reason {
+			t.Errorf("CompareReaderFile: got %v %q expected %v %q", eq, c.LastResult().Reason, v.want, v.reason)
+		}
+	}
+
+	// An open file is compared from its current position, and its size
+	// is used for the shortcut.
+	f := tmpFiles[1]
+	c := New(nil, Options{})
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if eq, err := c.CompareReaderFile(f, path); eq || err != nil || c.LastResult().Reason != ReasonSizeMismatch {
+		t.Errorf("CompareReaderFile: got %v %v %q expected size mismatch", eq, err, c.LastResult().Reason)
+	}
+	if _, err := f.Seek(3, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if eq, err := c.CompareReaderFile(f, path); !eq || err != nil {
+		t.Errorf("CompareReaderFile: got %v %v expected equal from offset 3", eq, err)
+	}
+}
+
+func TestFastHashForcesCompare(t *testing.T) {
+	pat := "equalfiles_test_fasthash"
+	contents := [][]byte{[]byte("abcdef"), []byte("abcdef"), []byte("abcxef")}
+	tmpFiles := makeTmpFiles(t, pat, contents)
+	defer cleanupTmpFiles(tmpFiles)
+
+	c := NewMultiple(nil, Options{}, NewFastHash(), false) // compareOnMatch must be forced
+	compare(t, c, tmpFiles[0].Name(), tmpFiles[1].Name(), expectEqual)
+	if r := c.LastResult(); r.Reason != ReasonContentMatch {
+		t.Errorf("fast hash match should be confirmed by byte comparison, got reason %q", r.Reason)
+	}
+	compare(t, c, tmpFiles[0].Name(), tmpFiles[2].Name(), expectUnequal)
+}
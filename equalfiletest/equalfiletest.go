@@ -0,0 +1,157 @@
+// Package equalfiletest provides test assertions comparing files and readers.
+//
+// Failures report the first mismatch offset, the sizes and a hex dump
+// around the difference:
+//
+//	func TestExport(t *testing.T) {
+//		export("out.bin")
+//		equalfiletest.AssertFilesEqual(t, "out.bin", "testdata/expected.bin")
+//	}
+package equalfiletest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/udhos/equalfile"
+	"github.com/udhos/equalfile/internal/diff"
+)
+
+const bufSize = 32 * 1024
+
+// AssertFilesEqual marks the test as failed if files a and b differ.
+func AssertFilesEqual(t testing.TB, a, b string) {
+	t.Helper()
+
+	cmp := equalfile.New(make([]byte, bufSize), equalfile.Options{})
+	equal, err := cmp.CompareFile(a, b)
+	if err != nil {
+		t.Errorf("equalfiletest: comparing %s and %s: %v", a, b, err)
+		return
+	}
+	if equal {
+		return
+	}
+
+	r := cmp.LastResult()
+
+	f1, err1 := os.Open(a)
+	if err1 != nil {
+		t.Errorf("equalfiletest: files %s and %s differ (%s): %v", a, b, r.Reason, err1)
+		return
+	}
+	defer f1.Close()
+	f2, err2 := os.Open(b)
+	if err2 != nil {
+		t.Errorf("equalfiletest: files %s and %s differ (%s): %v", a, b, r.Reason, err2)
+		return
+	}
+	defer f2.Close()
+
+	offset := r.Offset
+	if offset < 0 {
+		// size mismatch shortcut: compare the contents to find the offset
+		cmp.CompareReader(f1, f2)
+		offset = cmp.LastResult().Offset
+	}
+
+	start, end := diff.Window(offset)
+	w1 := readAt(f1, start, end)
+	w2 := readAt(f2, start, end)
+
+	t.Errorf("equalfiletest: files %s and %s differ (%s)\n%s%s", a, b, r.Reason,
+		diff.Summary(a, b, r.Size1, r.Size2, offset), diff.Hex(a, b, w1, w2, start))
+}
+
+// readAt returns the data of f within [start,end), or less near the end.
+func readAt(f *os.File, start, end int64) []byte {
+	buf := make([]byte, end-start)
+	n, _ := f.ReadAt(buf, start)
+	return buf[:n]
+}
+
+// AssertReadersEqual marks the test as failed if r1 and r2 provide
+// different contents. Both readers are read until the end.
+func AssertReadersEqual(t testing.TB, r1, r2 io.Reader) {
+	t.Helper()
+
+	t1 := &tailReader{r: r1}
+	t2 := &tailReader{r: r2}
+
+	cmp := equalfile.New(make([]byte, bufSize), equalfile.Options{})
+	equal, err := cmp.CompareReader(t1, t2)
+	if err != nil {
+		t.Errorf("equalfiletest: comparing readers: %v", err)
+		return
+	}
+	if equal {
+		return
+	}
+
+	r := cmp.LastResult()
+	start, end := diff.Window(r.Offset)
+
+	w1, size1, err1 := t1.finish(start, end)
+	if err1 != nil {
+		t.Errorf("equalfiletest: readers differ (%s): %v", r.Reason, err1)
+		return
+	}
+	w2, size2, err2 := t2.finish(start, end)
+	if err2 != nil {
+		t.Errorf("equalfiletest: readers differ (%s): %v", r.Reason, err2)
+		return
+	}
+
+	t.Errorf("equalfiletest: readers differ (%s)\n%s%s", r.Reason,
+		diff.Summary("reader1", "reader2", size1, size2, r.Offset), diff.Hex("reader1", "reader2", w1, w2, start))
+}
+
+// tailReader retains the most recent data read, so that the hex dump
+// window around a difference is still available after the comparison.
+type tailReader struct {
+	r    io.Reader
+	n    int64  // bytes read so far
+	tail []byte // last bytes read: [n-len(tail), n)
+}
+
+// keep covers a whole comparison buffer plus the window before it.
+const keep = 2 * bufSize
+
+func (t *tailReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.n += int64(n)
+	t.tail = append(t.tail, p[:n]...)
+	if len(t.tail) > 2*keep {
+		t.tail = append(t.tail[:0], t.tail[len(t.tail)-keep:]...)
+	}
+	return n, err
+}
+
+// finish reads past the window and up to the end, returning the data
+// within [start,end) and the total size.
+func (t *tailReader) finish(start, end int64) ([]byte, int64, error) {
+	if t.n < end {
+		if _, err := io.CopyN(ioutil.Discard, t, end-t.n); err != nil && err != io.EOF {
+			return nil, t.n, err
+		}
+	}
+
+	first := t.n - int64(len(t.tail))
+	if start < first {
+		return nil, t.n, fmt.Errorf("window at offset %d no longer available", start)
+	}
+	window := t.tail[start-first:]
+	if n := end - start; int64(len(window)) > n {
+		window = window[:n]
+	}
+	window = append([]byte(nil), window...)
+
+	if _, err := io.Copy(ioutil.Discard, t); err != nil {
+		return nil, t.n, err
+	}
+
+	return window, t.n, nil
+}
@@ -0,0 +1,107 @@
+package equalfiletest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// recorder captures failures instead of failing the test.
+type recorder struct {
+	testing.TB
+	failed bool
+	msg    string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failed = true
+	r.msg = fmt.Sprintf(format, args...)
+}
+
+func writeFiles(t *testing.T, contents ...[]byte) []string {
+	dir, err := ioutil.TempDir("", "equalfiletest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for i, c := range contents {
+		p := filepath.Join(dir, fmt.Sprintf("f%d", i))
+		if err := ioutil.WriteFile(p, c, 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+func TestAssertFilesEqual(t *testing.T) {
+	big := bytes.Repeat([]byte("0123456789abcdef"), 10000)
+	changed := append([]byte(nil), big...)
+	changed[100000] = 'X'
+
+	paths := writeFiles(t, big, append([]byte(nil), big...), changed, big[:1000])
+	defer os.RemoveAll(filepath.Dir(paths[0]))
+
+	AssertFilesEqual(t, paths[0], paths[1])
+
+	var tests = []struct {
+		other string
+		want  []string
+	}{
+		{other: paths[2], want: []string{"content mismatch", "offset 100000 (0x186a0)", "-000186a0  30", "+000186a0  58", "160000 bytes"}},
+		{other: paths[3], want: []string{"size mismatch", "offset 1000", "1000 bytes"}},
+		{other: paths[0] + ".missing", want: []string{"no such file"}},
+	}
+
+	for _, v := range tests {
+		r := &recorder{TB: t}
+		AssertFilesEqual(r, paths[0], v.other)
+		if !r.failed {
+			t.Errorf("AssertFilesEqual(%s): expected failure", v.other)
+			continue
+		}
+		for _, w := range v.want {
+			if !strings.Contains(r.msg, w) {
+				t.Errorf("AssertFilesEqual(%s): message missing %q:\n%s", v.other, w, r.msg)
+			}
+		}
+	}
+}
+
+func TestAssertReadersEqual(t *testing.T) {
+	big := bytes.Repeat([]byte("0123456789abcdef"), 10000)
+	changed := append([]byte(nil), big...)
+	changed[150000] = 'X'
+
+	AssertReadersEqual(t, bytes.NewReader(big), iotest.OneByteReader(bytes.NewReader(big)))
+
+	r := &recorder{TB: t}
+	AssertReadersEqual(r, bytes.NewReader(big), iotest.HalfReader(bytes.NewReader(changed)))
+	for _, w := range []string{"content mismatch", "offset 150000", "-000249f0  30", "+000249f0  58", "reader2: 160000 bytes"} {
+		if !strings.Contains(r.msg, w) {
+			t.Errorf("AssertReadersEqual: message missing %q:\n%s", w, r.msg)
+		}
+	}
+
+	r = &recorder{TB: t}
+	AssertReadersEqual(r, bytes.NewReader(big), bytes.NewReader(big[:5000]))
+	for _, w := range []string{"length mismatch", "offset 5000", "reader1: 160000 bytes", "reader2: 5000 bytes"} {
+		if !strings.Contains(r.msg, w) {
+			t.Errorf("AssertReadersEqual: message missing %q:\n%s", w, r.msg)
+		}
+	}
+}
+
+func BenchmarkAssertReadersEqual(b *testing.B) {
+	data := bytes.Repeat([]byte("x"), 1000000)
+	for i := 0; i < b.N; i++ {
+		AssertReadersEqual(b, bytes.NewReader(data), bytes.NewReader(data))
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !s390x
+// +build !s390x
+
+package equalfile
+
+// posix_fadvise advice values
+const (
+	fadvSequential = 2
+	fadvDontNeed   = 4
+)
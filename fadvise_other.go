@@ -0,0 +1,13 @@
+//go:build !linux || !(amd64 || arm64 || loong64 || mips64 || mips64le || ppc64 || ppc64le || riscv64 || s390x)
+// +build !linux !amd64,!arm64,!loong64,!mips64,!mips64le,!ppc64,!ppc64le,!riscv64,!s390x
+
+package equalfile
+
+import "os"
+
+// fadvise is a no-op where posix_fadvise is not supported, including 32-bit
+// Linux, where 64-bit offsets are split across registers differently on
+// each architecture.
+func fadvise(f *os.File, offset, length int64, advice int) error {
+	return nil
+}
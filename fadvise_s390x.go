@@ -0,0 +1,7 @@
+package equalfile
+
+// posix_fadvise advice values, which differ on s390x
+const (
+	fadvSequential = 2
+	fadvDontNeed   = 6
+)
@@ -0,0 +1,26 @@
+package equalfile
+
+import (
+	"hash"
+	"hash/maphash"
+)
+
+// fastHash is a non-cryptographic hash. Cmp never trusts a fastHash match
+// alone: it always confirms the match by comparing bytes.
+type fastHash struct {
+	maphash.Hash
+}
+
+// NewFastHash returns a fast non-cryptographic hash for NewMultiple.
+// It is much cheaper than cryptographic hashes like SHA-256, but collisions
+// are possible, so NewMultiple ignores compareOnMatch and always performs
+// byte-by-byte comparison when the hashes match. Hashes are seeded per
+// process, so they must not be stored or compared across processes.
+func NewFastHash() hash.Hash {
+	return &fastHash{}
+}
+
+func isFastHash(h hash.Hash) bool {
+	_, fast := h.(*fastHash)
+	return fast
+}
@@ -1,3 +1,3 @@
 module github.com/udhos/equalfile
 
-go 1.12
+go 1.14
@@ -0,0 +1,102 @@
+// Package golden compares test outputs against golden files.
+//
+//	func TestRender(t *testing.T) {
+//		got := render()
+//		golden.Assert(t, got, "testdata/render.golden")
+//	}
+//
+// Golden files are rewritten with the current outputs, instead of
+// compared, when the test binary has a boolean -update flag set. golden
+// does not define the flag, so that test packages which already have one
+// keep it. Others declare it:
+//
+//	var update = flag.Bool("update", false, "rewrite golden files")
+//
+// and run "go test -update".
+package golden
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/udhos/equalfile"
+	"github.com/udhos/equalfile/internal/diff"
+)
+
+// updating reports whether golden files should be rewritten, as set by
+// the -update flag of the test binary, if any.
+func updating() bool {
+	f := flag.Lookup("update")
+	if f == nil {
+		return false
+	}
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return false
+	}
+	update, _ := getter.Get().(bool)
+	return update
+}
+
+// Assert fails the test if got differs from the content of the golden
+// file at path. The failure shows a unified diff when both contents are
+// text, or a hex dump around the first difference otherwise.
+// With -update, the golden file is written instead.
+func Assert(t testing.TB, got []byte, path string) {
+	t.Helper()
+
+	if updating() {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("golden: %v", err)
+		}
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("golden: %v", err)
+		}
+		return
+	}
+
+	cmp := equalfile.New(nil, equalfile.Options{})
+	equal, err := cmp.CompareFileBytes(path, got)
+	if err != nil {
+		t.Fatalf("golden: %v (run with -update to create it)", err)
+	}
+	if equal {
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("golden: %v", err)
+	}
+
+	t.Errorf("golden: output differs from %s (run with -update to rewrite it):\n%s", path, Diff(path, "got", want, got))
+}
+
+// AssertString is like Assert, for text output.
+func AssertString(t testing.TB, got string, path string) {
+	t.Helper()
+	Assert(t, []byte(got), path)
+}
+
+// Diff describes how got differs from want: a unified diff for text, or
+// a hex dump around the first difference for binary data.
+func Diff(nameWant, nameGot string, want, got []byte) string {
+	if diff.IsText(want) && diff.IsText(got) {
+		if d := diff.Unified(nameWant, nameGot, want, got); d != "" {
+			return d
+		}
+	}
+
+	// compare readers to find the first difference even if sizes differ
+	cmp := equalfile.New(nil, equalfile.Options{})
+	cmp.CompareReader(bytes.NewReader(want), bytes.NewReader(got))
+	offset := cmp.LastResult().Offset
+
+	start, end := diff.Window(offset)
+	return diff.Hex(nameWant, nameGot, diff.Slice(want, start, end), diff.Slice(got, start, end), start) +
+		diff.Summary(nameWant, nameGot, int64(len(want)), int64(len(got)), offset)
+}
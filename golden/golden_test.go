@@ -0,0 +1,104 @@
+package golden
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// recorder captures failures instead of failing the test.
+type recorder struct {
+	testing.TB
+	failed bool
+	msg    string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failed = true
+	r.msg = fmt.Sprintf(format, args...)
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// run calls f like a test would, so that Fatalf stops it.
+func (r *recorder) run(f func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	<-done
+}
+
+func TestAssert(t *testing.T) {
+	Assert(t, []byte("alpha\nbeta\ngamma\n"), "testdata/text.golden")
+	Assert(t, []byte("\x00\x01\x02binary\xff"), "testdata/binary.golden")
+}
+
+func TestAssertMismatch(t *testing.T) {
+	if *update {
+		t.Skip("would rewrite golden files with mismatching content")
+	}
+
+	var tests = []struct {
+		path string
+		got  string
+		want []string // substrings of the failure message
+	}{
+		{path: "testdata/text.golden", got: "alpha\nBETA\ngamma\n", want: []string{"@@ -1,3 +1,3 @@", "-beta\n", "+BETA\n"}},
+		{path: "testdata/text.golden", got: "alpha\nbeta\ngamma", want: []string{"-gamma\n", "+gamma\n\\ No newline at end of file"}},
+		{path: "testdata/binary.golden", got: "\x00\x01\x03binary\xff", want: []string{"-00000000  00 01 02", "+00000000  00 01 03", "first difference at offset 2"}},
+		{path: "testdata/binary.golden", got: "\x00\x01\x02bin", want: []string{"first difference at offset 6", "got: 6 bytes"}},
+		{path: "testdata/missing.golden", got: "", want: []string{"-update"}},
+	}
+
+	for _, v := range tests {
+		r := &recorder{TB: t}
+		r.run(func() { Assert(r, []byte(v.got), v.path) })
+		if !r.failed {
+			t.Errorf("Assert(%q,%s): expected failure", v.got, v.path)
+			continue
+		}
+		for _, w := range v.want {
+			if !strings.Contains(r.msg, w) {
+				t.Errorf("Assert(%q,%s): message missing %q:\n%s", v.got, v.path, w, r.msg)
+			}
+		}
+	}
+}
+
+func TestUpdating(t *testing.T) {
+	defer func(fs *flag.FlagSet) { flag.CommandLine = fs }(flag.CommandLine)
+
+	flag.CommandLine = flag.NewFlagSet("test", flag.PanicOnError)
+	if updating() {
+		t.Errorf("updating: got true without -update flag")
+	}
+
+	flag.Bool("update", false, "")
+	if updating() {
+		t.Errorf("updating: got true before -update")
+	}
+	if err := flag.CommandLine.Parse([]string{"-update"}); err != nil {
+		t.Fatal(err)
+	}
+	if !updating() {
+		t.Errorf("updating: got false after -update")
+	}
+
+	// not a boolean flag
+	flag.CommandLine = flag.NewFlagSet("test", flag.PanicOnError)
+	flag.String("update", "yes", "")
+	if updating() {
+		t.Errorf("updating: got true for string flag")
+	}
+}
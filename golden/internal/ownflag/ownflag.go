@@ -0,0 +1,3 @@
+// Package ownflag tests golden from a package that declares its own
+// -update flag, as test packages did before adopting golden.
+package ownflag
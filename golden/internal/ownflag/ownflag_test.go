@@ -0,0 +1,35 @@
+package ownflag
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/udhos/equalfile/golden"
+)
+
+// defined after golden is initialized
+var update = flag.Bool("update", false, "rewrite golden files")
+
+func TestAssert(t *testing.T) {
+	golden.Assert(t, []byte("own flag\n"), "testdata/own.golden")
+}
+
+func TestUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "equalfile-ownflag")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer func(u bool) { *update = u }(*update)
+	*update = true
+
+	path := filepath.Join(dir, "new.golden")
+	golden.Assert(t, []byte("written\n"), path)
+	if b, err := ioutil.ReadFile(path); err != nil || string(b) != "written\n" {
+		t.Errorf("golden file not written through own -update flag: %q %v", b, err)
+	}
+}
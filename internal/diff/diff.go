@@ -0,0 +1,286 @@
+// Package diff formats differences between contents for test failure
+// messages: unified diffs for text and hex dumps for binary data.
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	context  = 3       // unified diff lines of context
+	maxTable = 4000000 // larger LCS tables fall back to a single hunk
+
+	hexRow    = 16
+	hexBefore = 2 // rows shown before the row with the first difference
+	hexRows   = 6
+)
+
+// IsText reports whether b looks like text: valid UTF-8 without NUL bytes.
+func IsText(b []byte) bool {
+	return utf8.Valid(b) && bytes.IndexByte(b, 0) < 0
+}
+
+type op struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// Unified returns a unified diff from a to b, or an empty string if the
+// contents are equal.
+func Unified(name1, name2 string, a, b []byte) string {
+	lines1 := splitLines(a)
+	lines2 := splitLines(b)
+
+	ops := lineOps(lines1, lines2)
+
+	var out strings.Builder
+
+	// line numbers (0-based) in each input at every op
+	pos1 := make([]int, len(ops)+1)
+	pos2 := make([]int, len(ops)+1)
+	for i, o := range ops {
+		pos1[i+1], pos2[i+1] = pos1[i], pos2[i]
+		if o.kind != '+' {
+			pos1[i+1]++
+		}
+		if o.kind != '-' {
+			pos2[i+1]++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// extend the hunk while changes are close enough to share context
+		last := i
+		for j := i + 1; j < len(ops) && j <= last+2*context; j++ {
+			if ops[j].kind != ' ' {
+				last = j
+			}
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := last + context + 1
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", name1, name2)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(pos1[start], pos1[end]), hunkRange(pos2[start], pos2[end]))
+		for _, o := range ops[start:end] {
+			out.WriteByte(o.kind)
+			out.WriteString(o.line)
+			if !strings.HasSuffix(o.line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		i = end
+	}
+
+	return out.String()
+}
+
+// hunkRange formats lines [start,end) like GNU diff.
+func hunkRange(start, end int) string {
+	n := end - start
+	switch n {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
+
+// splitLines splits b after each newline.
+func splitLines(b []byte) []string {
+	if len(b) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineOps computes an edit script from a to b using the longest common
+// subsequence of lines.
+func lineOps(a, b []string) []op {
+	var ops []op
+
+	// common prefix and suffix don't need the LCS table
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		ops = append(ops, op{' ', a[prefix]})
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	m1 := a[prefix : len(a)-suffix]
+	m2 := b[prefix : len(b)-suffix]
+
+	if len(m1)*len(m2) > maxTable {
+		for _, l := range m1 {
+			ops = append(ops, op{'-', l})
+		}
+		for _, l := range m2 {
+			ops = append(ops, op{'+', l})
+		}
+	} else {
+		ops = append(ops, lcsOps(m1, m2)...)
+	}
+
+	for _, l := range a[len(a)-suffix:] {
+		ops = append(ops, op{' ', l})
+	}
+
+	return ops
+}
+
+func lcsOps(a, b []string) []op {
+	// table[i][j] is the LCS length of a[i:] and b[j:]
+	w := len(b) + 1
+	table := make([]int, (len(a)+1)*w)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i*w+j] = table[(i+1)*w+j+1] + 1
+			} else if table[(i+1)*w+j] >= table[i*w+j+1] {
+				table[i*w+j] = table[(i+1)*w+j]
+			} else {
+				table[i*w+j] = table[i*w+j+1]
+			}
+		}
+	}
+
+	var ops []op
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{' ', a[i]})
+			i++
+			j++
+		case table[(i+1)*w+j] >= table[i*w+j+1]:
+			ops = append(ops, op{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, op{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, op{'+', b[j]})
+	}
+	return ops
+}
+
+// Window returns the range [start,end) of data shown by Hex for a
+// difference at offset.
+func Window(offset int64) (start, end int64) {
+	start = offset/hexRow*hexRow - hexBefore*hexRow
+	if start < 0 {
+		start = 0
+	}
+	return start, start + hexRows*hexRow
+}
+
+// Slice returns the part of b within [start,end).
+func Slice(b []byte, start, end int64) []byte {
+	if start > int64(len(b)) {
+		return nil
+	}
+	if end > int64(len(b)) {
+		end = int64(len(b))
+	}
+	return b[start:end]
+}
+
+// Summary reports sizes and the offset of the first difference.
+func Summary(name1, name2 string, size1, size2, offset int64) string {
+	return fmt.Sprintf("first difference at offset %d (0x%x); %s: %d bytes, %s: %d bytes\n",
+		offset, offset, name1, size1, name2, size2)
+}
+
+// Hex returns a hex dump of a and b, which hold data starting at offset
+// start, in the style of "hexdump -C". Rows that differ are shown for both
+// inputs, marked with '-' and '+'.
+func Hex(name1, name2 string, a, b []byte, start int64) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", name1, name2)
+
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+
+	for i := 0; i < n; i += hexRow {
+		row1 := hexSlice(a, i)
+		row2 := hexSlice(b, i)
+		offset := start + int64(i)
+		if bytes.Equal(row1, row2) {
+			hexLine(&out, ' ', offset, row1)
+			continue
+		}
+		if len(row1) > 0 {
+			hexLine(&out, '-', offset, row1)
+		}
+		if len(row2) > 0 {
+			hexLine(&out, '+', offset, row2)
+		}
+	}
+
+	return out.String()
+}
+
+func hexSlice(b []byte, i int) []byte {
+	if i >= len(b) {
+		return nil
+	}
+	end := i + hexRow
+	if end > len(b) {
+		end = len(b)
+	}
+	return b[i:end]
+}
+
+func hexLine(out *strings.Builder, mark byte, offset int64, row []byte) {
+	fmt.Fprintf(out, "%c%08x ", mark, offset)
+	for i := 0; i < hexRow; i++ {
+		if i == hexRow/2 {
+			out.WriteByte(' ')
+		}
+		if i < len(row) {
+			fmt.Fprintf(out, " %02x", row[i])
+		} else {
+			out.WriteString("   ")
+		}
+	}
+	out.WriteString("  |")
+	for _, c := range row {
+		if c < 0x20 || c > 0x7e {
+			c = '.'
+		}
+		out.WriteByte(c)
+	}
+	out.WriteString("|\n")
+}
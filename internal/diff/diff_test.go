@@ -0,0 +1,31 @@
+package diff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	var tests = []struct {
+		a, b string
+		want string
+	}{
+		{a: "a\nb\n", b: "a\nb\n", want: ""},
+		{a: "", b: "a\n", want: "--- 1\n+++ 2\n@@ -0,0 +1 @@\n+a\n"},
+		{a: "a\nb\nc\n", b: "a\nc\n", want: "--- 1\n+++ 2\n@@ -1,3 +1,2 @@\n a\n-b\n c\n"},
+		{
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b:    "1\nX\n3\n4\n5\n6\n7\n8\n9\n10\n11\nY\n",
+			want: "--- 1\n+++ 2\n@@ -1,5 +1,5 @@\n 1\n-2\n+X\n 3\n 4\n 5\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+Y\n",
+		},
+	}
+
+	for _, v := range tests {
+		if got := Unified("1", "2", []byte(v.a), []byte(v.b)); got != v.want {
+			t.Errorf("Unified(%q,%q):\ngot:\n%s\nexpected:\n%s", v.a, v.b, got, v.want)
+		}
+	}
+}
+
+func TestIsText(t *testing.T) {
+	if !IsText([]byte("héllo\n")) || IsText([]byte("a\x00b")) || IsText([]byte{0xff}) {
+		t.Errorf("IsText: wrong classification")
+	}
+}
@@ -0,0 +1,456 @@
+package equalfile
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Only the first 64MBytes of lines are sorted in memory by CompareLineSet,
+// the rest is spilled to temporary files.
+const defaultSortMemory = 64 * 1024 * 1024
+
+// Memory accounted per line on top of its bytes: the string header in the
+// slice, counted twice since append may double the slice, plus allocator
+// rounding of the string data.
+const lineOverhead = 48
+
+// mergeFanIn bounds how many spilled runs are merged, and so kept open,
+// at once. Lowered by tests.
+var mergeFanIn = 128
+
+// Reasons reported in Result by CompareLineSet.
+const (
+	ReasonLineSetMatch    = "line set match"    // same lines, possibly in another order
+	ReasonLineSetMismatch = "line set mismatch" // some line count differs
+)
+
+// LineDiff reports a line occurring a distinct number of times in each input.
+type LineDiff struct {
+	Line   string
+	Count1 int64 // occurrences in first input
+	Count2 int64 // occurrences in second input
+}
+
+// CompareLineSet verifies that two readers provide the same lines,
+// regardless of order: each input is taken as a multiset of lines.
+// Lines are separated by '\n', which is not part of the line; a missing
+// newline at the end of input is ignored.
+//
+// Inputs larger than Opt.SortMemory are sorted externally, using
+// temporary files. If report is not nil, it is called for every line with
+// distinct counts, in sorted order. Reading more than MaxSize from either
+// input returns an error.
+func (c *Cmp) CompareLineSet(r1, r2 io.Reader, report func(LineDiff)) (bool, error) {
+
+	c.resetResult(-1, -1)
+	c.resetDebugging()
+
+	lines1, size1, err1 := c.sortLines(r1)
+	if err1 != nil {
+		return c.resultErr(err1)
+	}
+	defer lines1.close()
+
+	lines2, size2, err2 := c.sortLines(r2)
+	if err2 != nil {
+		return c.resultErr(err2)
+	}
+	defer lines2.close()
+
+	c.last.Size1 = size1
+	c.last.Size2 = size2
+
+	equal := true
+
+	line1, count1, errNext1 := nextRun(lines1)
+	line2, count2, errNext2 := nextRun(lines2)
+
+	for count1 > 0 || count2 > 0 {
+		if errNext1 != nil {
+			return c.resultErr(errNext1)
+		}
+		if errNext2 != nil {
+			return c.resultErr(errNext2)
+		}
+
+		d := LineDiff{}
+		switch {
+		case count2 == 0 || (count1 > 0 && line1 < line2):
+			d = LineDiff{Line: line1, Count1: count1}
+			line1, count1, errNext1 = nextRun(lines1)
+		case count1 == 0 || line2 < line1:
+			d = LineDiff{Line: line2, Count2: count2}
+			line2, count2, errNext2 = nextRun(lines2)
+		default:
+			d = LineDiff{Line: line1, Count1: count1, Count2: count2}
+			line1, count1, errNext1 = nextRun(lines1)
+			line2, count2, errNext2 = nextRun(lines2)
+		}
+
+		if d.Count1 != d.Count2 {
+			equal = false
+			if report != nil {
+				report(d)
+			}
+		}
+	}
+
+	if errNext1 != nil {
+		return c.resultErr(errNext1)
+	}
+	if errNext2 != nil {
+		return c.resultErr(errNext2)
+	}
+
+	c.printDebugCompareReader()
+
+	if !equal {
+		return c.result(false, ReasonLineSetMismatch), nil
+	}
+	return c.result(true, ReasonLineSetMatch), nil
+}
+
+// CompareFileLineSet is like CompareLineSet, for files with names path1 and path2.
+func (c *Cmp) CompareFileLineSet(path1, path2 string, report func(LineDiff)) (bool, error) {
+	f1, openErr1 := c.storage().Open(path1)
+	if openErr1 != nil {
+		c.resetResult(-1, -1)
+		return c.resultErr(openErr1)
+	}
+	defer f1.Close()
+
+	f2, openErr2 := c.storage().Open(path2)
+	if openErr2 != nil {
+		c.resetResult(-1, -1)
+		return c.resultErr(openErr2)
+	}
+	defer f2.Close()
+
+	return c.CompareLineSet(f1, f2, report)
+}
+
+// lineIter yields lines in sorted order.
+type lineIter interface {
+	next() (string, bool, error)
+	close()
+}
+
+// nextRun returns the next distinct line and how many times it repeats.
+// count is zero at the end of input.
+func nextRun(it *mergeIter) (string, int64, error) {
+	line, ok, err := it.peek()
+	if !ok || err != nil {
+		return "", 0, err
+	}
+	var count int64
+	for {
+		l, more, errNext := it.peek()
+		if errNext != nil {
+			return "", 0, errNext
+		}
+		if !more || l != line {
+			return line, count, nil
+		}
+		it.advance()
+		count++
+	}
+}
+
+// sortLines reads all lines from r, returning them in sorted order along
+// with the number of bytes read. Runs that exceed the memory budget are
+// sorted and written to temporary files, then merged. Whenever mergeFanIn
+// runs of the same level pile up, they are merged into a single run of the
+// next level, so the number of open files stays bounded.
+func (c *Cmp) sortLines(r io.Reader) (*mergeIter, int64, error) {
+	budget := c.Opt.SortMemory
+	if budget < 1 {
+		budget = defaultSortMemory
+	}
+	maxSize := c.Opt.MaxSize
+	if maxSize < 1 {
+		maxSize = defaultMaxSize
+	}
+
+	br := bufio.NewReader(&cmpReader{c: c, r: r})
+
+	var levels [][]lineIter // levels[i] runs merge mergeFanIn^i spills each
+	var chunk []string
+	var used, total int64
+
+	allRuns := func() []lineIter {
+		var runs []lineIter
+		for _, l := range levels {
+			runs = append(runs, l...)
+		}
+		return runs
+	}
+	closeRuns := func() {
+		for _, run := range allRuns() {
+			run.close()
+		}
+	}
+	addRun := func(run lineIter) error {
+		for l := 0; ; l++ {
+			if l == len(levels) {
+				levels = append(levels, nil)
+			}
+			levels[l] = append(levels[l], run)
+			if len(levels[l]) < mergeFanIn {
+				return nil
+			}
+			merged, err := c.mergeRuns(levels[l])
+			levels[l] = nil
+			if err != nil {
+				return err
+			}
+			run = merged
+		}
+	}
+
+	for {
+		line, errRead := br.ReadString('\n')
+		total += int64(len(line))
+		if total > maxSize {
+			closeRuns()
+			return nil, total, fmt.Errorf("max read size reached")
+		}
+		if errRead != nil && errRead != io.EOF {
+			closeRuns()
+			return nil, total, errRead
+		}
+		if line != "" {
+			line = strings.TrimSuffix(line, "\n")
+			chunk = append(chunk, line)
+			used += int64(len(line)) + lineOverhead
+		}
+		if used >= budget || (errRead == io.EOF && len(levels) > 0 && len(chunk) > 0) {
+			run, errSpill := c.spillLines(chunk)
+			if errSpill == nil {
+				errSpill = addRun(run)
+			}
+			if errSpill != nil {
+				closeRuns()
+				return nil, total, errSpill
+			}
+			chunk = nil
+			used = 0
+		}
+		if errRead == io.EOF {
+			break
+		}
+	}
+
+	runs := allRuns()
+	if len(levels) == 0 {
+		// everything fit in memory
+		sort.Strings(chunk)
+		runs = append(runs, &sliceIter{lines: chunk})
+	}
+
+	// at most mergeFanIn-1 runs are left per level
+	for len(runs) > mergeFanIn {
+		merged, errMerge := c.mergeRuns(runs[:mergeFanIn])
+		if errMerge != nil {
+			for _, run := range runs[mergeFanIn:] {
+				run.close()
+			}
+			return nil, total, errMerge
+		}
+		runs = append([]lineIter{merged}, runs[mergeFanIn:]...)
+	}
+
+	it, err := newMergeIter(runs)
+	if err != nil {
+		for _, run := range runs {
+			run.close()
+		}
+		return nil, total, err
+	}
+	return it, total, nil
+}
+
+// spillLines sorts lines and writes them to a temporary file.
+func (c *Cmp) spillLines(lines []string) (lineIter, error) {
+	sort.Strings(lines)
+	c.debugf("spillLines: %d lines\n", len(lines))
+	return c.writeRun(&sliceIter{lines: lines})
+}
+
+// mergeRuns merges runs into a single run written to a temporary file.
+// The runs are closed.
+func (c *Cmp) mergeRuns(runs []lineIter) (lineIter, error) {
+	c.debugf("mergeRuns: %d runs\n", len(runs))
+	it, err := newMergeIter(runs)
+	if err != nil {
+		for _, run := range runs {
+			run.close()
+		}
+		return nil, err
+	}
+	defer it.close()
+	return c.writeRun(it)
+}
+
+// writeRun writes the lines from it to a temporary file.
+func (c *Cmp) writeRun(it lineIter) (lineIter, error) {
+	f, err := ioutil.TempFile("", "equalfile-lines-*")
+	if err != nil {
+		return nil, err
+	}
+
+	w := bufio.NewWriter(f)
+	for {
+		l, ok, err := it.next()
+		if err != nil {
+			removeTmp(f)
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		if _, err := w.WriteString(l); err != nil {
+			removeTmp(f)
+			return nil, err
+		}
+		if err := w.WriteByte('\n'); err != nil {
+			removeTmp(f)
+			return nil, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		removeTmp(f)
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		removeTmp(f)
+		return nil, err
+	}
+
+	return &fileIter{f: f, r: bufio.NewReader(f)}, nil
+}
+
+func removeTmp(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+type sliceIter struct {
+	lines []string
+}
+
+func (it *sliceIter) next() (string, bool, error) {
+	if len(it.lines) == 0 {
+		return "", false, nil
+	}
+	l := it.lines[0]
+	it.lines = it.lines[1:]
+	return l, true, nil
+}
+
+func (it *sliceIter) close() {}
+
+type fileIter struct {
+	f *os.File
+	r *bufio.Reader
+}
+
+func (it *fileIter) next() (string, bool, error) {
+	line, err := it.r.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return "", false, nil
+	}
+	if err != nil && err != io.EOF {
+		return "", false, err
+	}
+	return strings.TrimSuffix(line, "\n"), true, nil
+}
+
+func (it *fileIter) close() {
+	removeTmp(it.f)
+}
+
+// mergeIter merges sorted runs with a heap keyed by each run's current line.
+type mergeIter struct {
+	runs []lineIter
+	h    lineHeap
+	err  error
+}
+
+type lineHeapItem struct {
+	line string
+	run  int
+}
+
+type lineHeap []lineHeapItem
+
+func (h lineHeap) Len() int            { return len(h) }
+func (h lineHeap) Less(i, j int) bool  { return h[i].line < h[j].line }
+func (h lineHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *lineHeap) Push(x interface{}) { *h = append(*h, x.(lineHeapItem)) }
+func (h *lineHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+func newMergeIter(runs []lineIter) (*mergeIter, error) {
+	it := &mergeIter{runs: runs}
+	for i, run := range runs {
+		line, ok, err := run.next()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			it.h = append(it.h, lineHeapItem{line, i})
+		}
+	}
+	heap.Init(&it.h)
+	return it, nil
+}
+
+// peek returns the smallest current line without consuming it.
+func (it *mergeIter) peek() (string, bool, error) {
+	if it.err != nil {
+		return "", false, it.err
+	}
+	if len(it.h) == 0 {
+		return "", false, nil
+	}
+	return it.h[0].line, true, nil
+}
+
+// advance consumes the smallest current line.
+func (it *mergeIter) advance() {
+	top := &it.h[0]
+	line, ok, err := it.runs[top.run].next()
+	switch {
+	case err != nil:
+		it.err = err
+	case ok:
+		top.line = line
+		heap.Fix(&it.h, 0)
+	default:
+		heap.Pop(&it.h)
+	}
+}
+
+func (it *mergeIter) next() (string, bool, error) {
+	line, ok, err := it.peek()
+	if ok {
+		it.advance()
+	}
+	return line, ok, err
+}
+
+func (it *mergeIter) close() {
+	for _, run := range it.runs {
+		run.close()
+	}
+}
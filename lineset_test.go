@@ -0,0 +1,126 @@
+package equalfile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompareLineSet(t *testing.T) {
+	var tests = []struct {
+		s1, s2 string
+		want   bool
+		diffs  []LineDiff
+	}{
+		{s1: "", s2: "", want: true},
+		{s1: "a\nb\nc\n", s2: "c\na\nb\n", want: true},
+		{s1: "a\nb\nc", s2: "c\nb\na\n", want: true},
+		{s1: "a\na\nb\n", s2: "b\na\na\n", want: true},
+		{s1: "a\nb\n", s2: "a\nc\n", want: false, diffs: []LineDiff{{"b", 1, 0}, {"c", 0, 1}}},
+		{s1: "a\na\nb\n", s2: "a\nb\n", want: false, diffs: []LineDiff{{"a", 2, 1}}},
+		{s1: "a\n\n", s2: "a\n", want: false, diffs: []LineDiff{{"", 1, 0}}},
+	}
+
+	for _, sortMemory := range []int64{0, 1, 40} {
+		c := New(nil, Options{SortMemory: sortMemory})
+		for _, v := range tests {
+			var diffs []LineDiff
+			eq, err := c.CompareLineSet(strings.NewReader(v.s1), strings.NewReader(v.s2), func(d LineDiff) {
+				diffs = append(diffs, d)
+			})
+			if err != nil {
+				t.Errorf("CompareLineSet(%q,%q) sortMemory=%d: %v", v.s1, v.s2, sortMemory, err)
+				continue
+			}
+			if eq != v.want || !reflect.DeepEqual(diffs, v.diffs) {
+				t.Errorf("CompareLineSet(%q,%q) sortMemory=%d: got %v %v expected %v %v",
+					v.s1, v.s2, sortMemory, eq, diffs, v.want, v.diffs)
+			}
+			r := c.LastResult()
+			if r.Equal != v.want || r.Size1 != int64(len(v.s1)) || r.Size2 != int64(len(v.s2)) {
+				t.Errorf("CompareLineSet(%q,%q) sortMemory=%d: bad result %+v", v.s1, v.s2, sortMemory, r)
+			}
+		}
+	}
+}
+
+func TestCompareLineSetSpill(t *testing.T) {
+	var b1, b2 strings.Builder
+	const n = 1000
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b1, "line %d\n", i)
+		fmt.Fprintf(&b2, "line %d\n", (i*7)%n) // 7 is coprime to n: a permutation
+	}
+
+	c := New(nil, Options{SortMemory: 512})
+	eq, err := c.CompareLineSet(strings.NewReader(b1.String()), strings.NewReader(b2.String()), nil)
+	if !eq || err != nil {
+		t.Errorf("CompareLineSet: got %v %v expected true", eq, err)
+	}
+
+	eq, err = c.CompareLineSet(strings.NewReader(b1.String()+"extra\n"), strings.NewReader(b2.String()), nil)
+	if eq || err != nil || c.LastResult().Reason != ReasonLineSetMismatch {
+		t.Errorf("CompareLineSet: got %v %v %q expected false", eq, err, c.LastResult().Reason)
+	}
+}
+
+func TestCompareLineSetMultiPass(t *testing.T) {
+	tmp, errTmp := ioutil.TempDir("", "equalfile-multipass")
+	if errTmp != nil {
+		t.Fatal(errTmp)
+	}
+	defer os.RemoveAll(tmp)
+	defer os.Setenv("TMPDIR", os.Getenv("TMPDIR"))
+	os.Setenv("TMPDIR", tmp)
+
+	defer func(fanIn int) { mergeFanIn = fanIn }(mergeFanIn)
+	mergeFanIn = 3
+
+	var b1, b2 strings.Builder
+	const n = 2000
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b1, "line %d\n", i%500) // repeated lines
+		fmt.Fprintf(&b2, "line %d\n", ((i*7)%n)%500)
+	}
+	fmt.Fprintf(&b1, "extra\n")
+
+	// about 35 lines per run, so about 55 runs per input
+	var diffs []LineDiff
+	maxOpen := 0
+	report := func(d LineDiff) {
+		diffs = append(diffs, d)
+		list, err := ioutil.ReadDir(tmp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) > maxOpen {
+			maxOpen = len(list)
+		}
+	}
+	c := New(nil, Options{SortMemory: 2000})
+	eq, err := c.CompareLineSet(strings.NewReader(b1.String()), strings.NewReader(b2.String()), report)
+	if eq || err != nil {
+		t.Errorf("CompareLineSet: got %v %v expected false", eq, err)
+	}
+	if len(diffs) != 1 || diffs[0] != (LineDiff{Line: "extra", Count1: 1}) {
+		t.Errorf("CompareLineSet: unexpected diffs %v", diffs)
+	}
+	if maxOpen == 0 || maxOpen > 2*mergeFanIn {
+		t.Errorf("CompareLineSet: %d runs open while merging, expected 1 to %d", maxOpen, 2*mergeFanIn)
+	}
+
+	if list, _ := ioutil.ReadDir(tmp); len(list) != 0 {
+		t.Errorf("CompareLineSet: %d temporary files left", len(list))
+	}
+}
+
+func TestCompareLineSetMaxSize(t *testing.T) {
+	c := New(nil, Options{MaxSize: 4})
+	_, err := c.CompareLineSet(strings.NewReader("a\nb\nc\n"), strings.NewReader("a\n"), nil)
+	if err == nil {
+		t.Errorf("CompareLineSet: expected error beyond MaxSize")
+	}
+}
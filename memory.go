@@ -0,0 +1,101 @@
+package equalfile
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// CompareBytes verifies that two byte slices have same contents.
+// Since lengths are known, MaxSize does not apply.
+// Details about the comparison are available from LastResult.
+func (c *Cmp) CompareBytes(b1, b2 []byte) (bool, error) {
+	c.resetResult(int64(len(b1)), int64(len(b2)))
+
+	if len(b1) != len(b2) {
+		c.debugf("CompareBytes: distinct sizes\n")
+		return c.result(false, ReasonSizeMismatch), nil
+	}
+
+	if i := mismatchIndex(b1, b2); i < len(b1) {
+		c.debugf("CompareBytes: found byte mismatch\n")
+		c.last.Offset = int64(i)
+		return c.result(false, ReasonContentMismatch), nil
+	}
+
+	return c.result(true, ReasonContentMatch), nil
+}
+
+// CompareFileString verifies that file path has content s.
+// See CompareFileBytes.
+func (c *Cmp) CompareFileString(path, s string) (bool, error) {
+	return c.CompareFileBytes(path, []byte(s))
+}
+
+// CompareFileBytes verifies that file path has content data.
+//
+// If path is a regular file, its size is compared against len(data) first.
+// In multiple mode, the file hash is taken from the hash cache (or added
+// to it), so comparing the same file repeatedly reads it only once.
+// Hashing is skipped when MaxSize is smaller than data.
+// Details about the comparison are available from LastResult.
+func (c *Cmp) CompareFileBytes(path string, data []byte) (bool, error) {
+
+	c.resetResult(-1, int64(len(data)))
+
+	if c.Opt.MaxSize < 0 {
+		return c.resultErr(fmt.Errorf("negative MaxSize"))
+	}
+
+	f, info, openErr := c.openStat(path)
+	if openErr != nil {
+		return c.resultErr(openErr)
+	}
+	defer f.Close()
+
+	c.resetResult(info.Size(), int64(len(data)))
+
+	regular := info.Mode().IsRegular()
+
+	if regular && info.Size() != int64(len(data)) {
+		c.debugf("CompareFileBytes(%s): distinct sizes\n", path)
+		return c.result(false, ReasonSizeMismatch), nil
+	}
+
+	// Regular file sizes are equal, so data length is a safe limit.
+	maxSize := c.Opt.MaxSize
+	if maxSize == 0 {
+		maxSize = int64(len(data))
+		if maxSize == 0 || !regular {
+			maxSize = defaultMaxSize
+		}
+	}
+
+	// Hashes cover at most maxSize bytes of the file, so they can't be
+	// compared against data beyond that.
+	if c.multipleMode() && maxSize >= int64(len(data)) {
+		h1, err1 := c.getHash(path, maxSize, PhaseHash1)
+		if err1 != nil {
+			return c.resultErr(err1)
+		}
+		c.hashType.Reset()
+		c.hashType.Write(data)
+		h2 := c.hashType.Sum(nil)
+		c.last.Hash1 = h1
+		c.last.Hash2 = h2
+		if !bytes.Equal(h1, h2) {
+			return c.result(false, ReasonHashMismatch), nil
+		}
+		if !c.hashMatchCompare {
+			return c.result(true, ReasonHashMatch), nil
+		}
+		c.debugf("CompareFileBytes(%s): hash match, will compare bytes\n", path)
+	}
+
+	c.resetDebugging()
+
+	eq, err := c.compareReader(f, bytes.NewReader(data), maxSize)
+
+	c.printDebugCompareReader()
+
+	return eq, err
+}
@@ -0,0 +1,74 @@
+package equalfile
+
+import (
+	"crypto/sha256"
+	"testing"
+)
+
+func TestCompareBytes(t *testing.T) {
+	var tests = []struct {
+		b1, b2 string
+		want   bool
+		reason string
+		offset int64
+	}{
+		{b1: "abc", b2: "abc", want: true, reason: ReasonContentMatch, offset: -1},
+		{b1: "", b2: "", want: true, reason: ReasonContentMatch, offset: -1},
+		{b1: "abc", b2: "abx", want: false, reason: ReasonContentMismatch, offset: 2},
+		{b1: "abc", b2: "abcd", want: false, reason: ReasonSizeMismatch, offset: -1},
+	}
+
+	c := New(nil, Options{})
+	for _, v := range tests {
+		eq, err := c.CompareBytes([]byte(v.b1), []byte(v.b2))
+		r := c.LastResult()
+		if eq != v.want || err != nil || r.Reason != v.reason || r.Offset != v.offset {
+			t.Errorf("CompareBytes(%q,%q): got %v %v %q %d expected %v %q %d",
+				v.b1, v.b2, eq, err, r.Reason, r.Offset, v.want, v.reason, v.offset)
+		}
+	}
+}
+
+func TestCompareFileBytes(t *testing.T) {
+	pat := "equalfiles_test_filebytes"
+	contents := [][]byte{[]byte("abcdef")}
+	tmpFiles := makeTmpFiles(t, pat, contents)
+	defer cleanupTmpFiles(tmpFiles)
+
+	path := tmpFiles[0].Name()
+
+	var tests = []struct {
+		c      *Cmp
+		data   string
+		want   bool
+		reason string
+	}{
+		{c: New(nil, Options{}), data: "abcdef", want: true, reason: ReasonContentMatch},
+		{c: New(nil, Options{}), data: "abcdex", want: false, reason: ReasonContentMismatch},
+		{c: New(nil, Options{}), data: "abc", want: false, reason: ReasonSizeMismatch},
+		{c: NewMultiple(nil, Options{}, sha256.New(), false), data: "abcdef", want: true, reason: ReasonHashMatch},
+		{c: NewMultiple(nil, Options{}, sha256.New(), false), data: "abcdex", want: false, reason: ReasonHashMismatch},
+		{c: NewMultiple(nil, Options{}, sha256.New(), true), data: "abcdef", want: true, reason: ReasonContentMatch},
+	}
+
+	for _, v := range tests {
+		eq, err := v.c.CompareFileString(path, v.data)
+		r := v.c.LastResult()
+		if eq != v.want || err != nil || r.Reason != v.reason {
+			t.Errorf("CompareFileString(%q): got %v %v %q expected %v %q", v.data, eq, err, r.Reason, v.want, v.reason)
+		}
+	}
+
+	// MaxSize truncates the comparison the same way in both modes
+	for _, c := range []*Cmp{New(nil, Options{MaxSize: 3}), NewMultiple(nil, Options{MaxSize: 3}, sha256.New(), false)} {
+		eq, err := c.CompareFileString(path, "abcdef")
+		if r := c.LastResult(); !eq || err == nil || r.Reason != ReasonMaxSize {
+			t.Errorf("CompareFileString with MaxSize: got %v %v %q expected true with error", eq, err, r.Reason)
+		}
+	}
+
+	c := New(nil, Options{})
+	if _, err := c.CompareFileBytes(path+"_missing", nil); err == nil {
+		t.Errorf("CompareFileBytes: missing expected error for missing file")
+	}
+}
@@ -0,0 +1,152 @@
+package equalfile
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Metadata selects file attributes compared by CompareFile in addition to
+// content. Values may be combined with bitwise OR.
+type Metadata int
+
+const (
+	MetadataMode    Metadata = 1 << iota // permission bits, setuid, setgid and sticky
+	MetadataOwner                        // owner uid and gid (Unix only)
+	MetadataModTime                      // modification time, within Options.ModTimeTolerance
+	MetadataXattr                        // extended attributes (Linux only)
+)
+
+var metadataNames = []struct {
+	m    Metadata
+	name string
+}{
+	{MetadataMode, "mode"},
+	{MetadataOwner, "owner"},
+	{MetadataModTime, "mtime"},
+	{MetadataXattr, "xattr"},
+}
+
+// String returns a comma separated list like "mode,mtime".
+func (m Metadata) String() string {
+	var names []string
+	for _, n := range metadataNames {
+		if m&n.m != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// ParseMetadata parses a comma separated list of attribute names as
+// produced by Metadata.String.
+func ParseMetadata(s string) (Metadata, error) {
+	var m Metadata
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, n := range metadataNames {
+			if n.name == name {
+				m |= n.m
+				found = true
+				break
+			}
+		}
+		if !found {
+			return m, fmt.Errorf("unknown metadata [%s]", name)
+		}
+	}
+	return m, nil
+}
+
+// compareMetadata records in Result.MetadataDiff which attributes selected
+// by Opt.Metadata differ between path1 and path2.
+func (c *Cmp) compareMetadata(path1, path2 string) error {
+	stat := c.storage().Stat
+	if c.Opt.Symlinks == SymlinkCompareTarget && c.localStorage() {
+		stat = os.Lstat
+	}
+
+	info1, statErr1 := stat(path1)
+	if statErr1 != nil {
+		return statErr1
+	}
+	info2, statErr2 := stat(path2)
+	if statErr2 != nil {
+		return statErr2
+	}
+
+	var diff Metadata
+
+	if c.Opt.Metadata&MetadataMode != 0 {
+		const bits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+		if info1.Mode()&bits != info2.Mode()&bits {
+			diff |= MetadataMode
+		}
+	}
+
+	if c.Opt.Metadata&MetadataModTime != 0 {
+		delta := info1.ModTime().Sub(info2.ModTime())
+		if delta < 0 {
+			delta = -delta
+		}
+		if delta > c.Opt.ModTimeTolerance {
+			diff |= MetadataModTime
+		}
+	}
+
+	if c.Opt.Metadata&MetadataOwner != 0 {
+		uid1, gid1, err1 := fileOwner(info1)
+		if err1 != nil {
+			return err1
+		}
+		uid2, gid2, err2 := fileOwner(info2)
+		if err2 != nil {
+			return err2
+		}
+		if uid1 != uid2 || gid1 != gid2 {
+			diff |= MetadataOwner
+		}
+	}
+
+	if c.Opt.Metadata&MetadataXattr != 0 {
+		if !c.localStorage() {
+			return fmt.Errorf("xattr comparison requires the local filesystem")
+		}
+		follow := c.Opt.Symlinks != SymlinkCompareTarget // as stat above
+		x1, err1 := fileXattrs(path1, follow)
+		if err1 != nil {
+			return err1
+		}
+		x2, err2 := fileXattrs(path2, follow)
+		if err2 != nil {
+			return err2
+		}
+		if !sameXattrs(x1, x2) {
+			diff |= MetadataXattr
+		}
+	}
+
+	c.last.MetadataDiff = diff
+
+	if diff != 0 {
+		c.debugf("CompareFile(%s,%s): metadata differ: %v\n", path1, path2, diff)
+	}
+
+	return nil
+}
+
+func sameXattrs(x1, x2 map[string]string) bool {
+	if len(x1) != len(x2) {
+		return false
+	}
+	for k, v1 := range x1 {
+		if v2, found := x2[k]; !found || v1 != v2 {
+			return false
+		}
+	}
+	return true
+}
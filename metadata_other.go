@@ -0,0 +1,13 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package equalfile
+
+import (
+	"fmt"
+	"os"
+)
+
+func fileOwner(info os.FileInfo) (uint32, uint32, error) {
+	return 0, 0, fmt.Errorf("owner comparison not supported on this platform")
+}
@@ -0,0 +1,18 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package equalfile
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+func fileOwner(info os.FileInfo) (uint32, uint32, error) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, fmt.Errorf("%s: owner not available", info.Name())
+	}
+	return st.Uid, st.Gid, nil
+}
@@ -0,0 +1,80 @@
+package equalfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Hash table keys for names start with a NUL byte, which can't appear in
+// file paths.
+const namedKeyPrefix = "\x00named:"
+
+func namedKey(name string) string {
+	return namedKeyPrefix + name
+}
+
+// OpenFunc opens a fresh reader positioned at the start of some content.
+type OpenFunc func() (io.ReadCloser, error)
+
+// CompareNamedReader verifies that two re-openable sources provide same
+// content. Each source is identified by a stable name, which is used as
+// the key for the multiple mode hash cache, so a source compared many
+// times is read and hashed only once. Names are kept apart from the file
+// paths cached by CompareFile, so a name never matches a path.
+//
+// In single mode, CompareNamedReader opens both sources and behaves like
+// CompareReader. MaxSize applies as in CompareReader.
+// Details about the comparison are available from LastResult.
+func (c *Cmp) CompareNamedReader(name1 string, open1 OpenFunc, name2 string, open2 OpenFunc) (bool, error) {
+
+	c.resetResult(-1, -1)
+
+	if c.Opt.MaxSize < 0 {
+		return c.resultErr(fmt.Errorf("negative MaxSize"))
+	}
+
+	if c.multipleMode() {
+		maxSize := c.Opt.MaxSize
+		if maxSize == 0 {
+			maxSize = defaultMaxSize
+		}
+		h1, err1 := c.getReaderHash(namedKey(name1), name1, open1, maxSize, PhaseHash1)
+		if err1 != nil {
+			return c.resultErr(err1)
+		}
+		h2, err2 := c.getReaderHash(namedKey(name2), name2, open2, maxSize, PhaseHash2)
+		if err2 != nil {
+			return c.resultErr(err2)
+		}
+		c.last.Hash1 = h1
+		c.last.Hash2 = h2
+		if !bytes.Equal(h1, h2) {
+			return c.result(false, ReasonHashMismatch), nil
+		}
+		if !c.hashMatchCompare {
+			return c.result(true, ReasonHashMatch), nil
+		}
+		c.debugf("CompareNamedReader(%s,%s): hash match, will compare bytes\n", name1, name2)
+	}
+
+	r1, openErr1 := open1()
+	if openErr1 != nil {
+		return c.resultErr(openErr1)
+	}
+	defer r1.Close()
+
+	r2, openErr2 := open2()
+	if openErr2 != nil {
+		return c.resultErr(openErr2)
+	}
+	defer r2.Close()
+
+	c.resetDebugging()
+
+	eq, err := c.compareReader(r1, r2, c.Opt.MaxSize)
+
+	c.printDebugCompareReader()
+
+	return eq, err
+}
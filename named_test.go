@@ -0,0 +1,96 @@
+package equalfile
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// blobStore counts how many times each blob is opened.
+type blobStore struct {
+	blobs map[string]string
+	opens map[string]int
+}
+
+func (s *blobStore) opener(name string) OpenFunc {
+	return func() (io.ReadCloser, error) {
+		s.opens[name]++
+		b, found := s.blobs[name]
+		if !found {
+			return nil, fmt.Errorf("blob not found: %s", name)
+		}
+		return ioutil.NopCloser(strings.NewReader(b)), nil
+	}
+}
+
+func (s *blobStore) compare(c *Cmp, name1, name2 string) (bool, error) {
+	return c.CompareNamedReader(name1, s.opener(name1), name2, s.opener(name2))
+}
+
+func TestCompareNamedReader(t *testing.T) {
+	s := &blobStore{
+		blobs: map[string]string{"a": "hello", "b": "hello", "c": "world"},
+		opens: map[string]int{},
+	}
+
+	c := NewMultiple(nil, Options{}, sha256.New(), false)
+
+	if eq, err := s.compare(c, "a", "b"); !eq || err != nil || c.LastResult().Reason != ReasonHashMatch {
+		t.Errorf("CompareNamedReader(a,b): got %v %v %q expected hash match", eq, err, c.LastResult().Reason)
+	}
+	if eq, err := s.compare(c, "a", "c"); eq || err != nil || c.LastResult().Reason != ReasonHashMismatch {
+		t.Errorf("CompareNamedReader(a,c): got %v %v %q expected hash mismatch", eq, err, c.LastResult().Reason)
+	}
+	if eq, err := s.compare(c, "b", "c"); eq || err != nil {
+		t.Errorf("CompareNamedReader(b,c): got %v %v expected unequal", eq, err)
+	}
+	for name, count := range s.opens {
+		if count != 1 {
+			t.Errorf("blob %s opened %d times, expected once", name, count)
+		}
+	}
+
+	if _, err := s.compare(c, "a", "missing"); err == nil {
+		t.Errorf("CompareNamedReader: missing expected error for missing blob")
+	}
+
+	// single mode and byte verification read the blobs
+	for _, c := range []*Cmp{New(nil, Options{}), NewMultiple(nil, Options{}, sha256.New(), true)} {
+		if eq, err := s.compare(c, "a", "b"); !eq || err != nil || c.LastResult().Reason != ReasonContentMatch {
+			t.Errorf("CompareNamedReader(a,b): got %v %v %q expected content match", eq, err, c.LastResult().Reason)
+		}
+		if eq, err := s.compare(c, "a", "c"); eq || err != nil {
+			t.Errorf("CompareNamedReader(a,c): got %v %v expected unequal", eq, err)
+		}
+	}
+}
+
+func TestCompareNamedReaderPathCollision(t *testing.T) {
+	pat := "equalfiles_test_named_collision"
+	tmpFiles := makeTmpFiles(t, pat, [][]byte{[]byte("local file"), []byte("other file")})
+	defer cleanupTmpFiles(tmpFiles)
+	path1, path2 := tmpFiles[0].Name(), tmpFiles[1].Name()
+
+	// blob named like path1, with the content of path2
+	s := &blobStore{
+		blobs: map[string]string{path1: "other file", "other": "other file"},
+		opens: map[string]int{},
+	}
+
+	c := NewMultiple(nil, Options{}, sha256.New(), false)
+	if eq, err := s.compare(c, path1, "other"); !eq || err != nil {
+		t.Errorf("CompareNamedReader: got %v %v expected true", eq, err)
+	}
+	if eq, err := c.CompareFile(path1, path2); eq || err != nil {
+		t.Errorf("CompareFile: got %v %v expected false: blob hash used for file", eq, err)
+	}
+	if eq, err := s.compare(c, path1, "other"); !eq || err != nil {
+		t.Errorf("CompareNamedReader: got %v %v expected true: file hash used for blob", eq, err)
+	}
+	if s.opens[path1] != 1 {
+		t.Errorf("blob opened %d times, expected once", s.opens[path1])
+	}
+}
@@ -0,0 +1,19 @@
+package equalfile
+
+import (
+	"os"
+	"syscall"
+)
+
+// openFile opens name for reading, with O_NOATIME if noAtime is set and
+// permitted: the kernel refuses it with EPERM unless the caller owns the
+// file or has CAP_FOWNER, in which case the file is opened normally.
+func openFile(name string, noAtime bool) (*os.File, error) {
+	if noAtime {
+		f, err := os.OpenFile(name, os.O_RDONLY|syscall.O_NOATIME, 0)
+		if pe, isPathErr := err.(*os.PathError); !isPathErr || pe.Err != syscall.EPERM {
+			return f, err
+		}
+	}
+	return os.Open(name)
+}
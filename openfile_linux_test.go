@@ -0,0 +1,99 @@
+package equalfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func atime(t *testing.T, path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := info.Sys().(*syscall.Stat_t)
+	return time.Unix(int64(st.Atim.Sec), int64(st.Atim.Nsec))
+}
+
+func TestNoAtimeDropCache(t *testing.T) {
+	pat := "equalfiles_test_noatime"
+	data := bytes.Repeat([]byte("0123456789"), 2*dropChunk/10+1)
+	tmpFiles := makeTmpFiles(t, pat, [][]byte{data, data})
+	defer cleanupTmpFiles(tmpFiles)
+
+	path1 := tmpFiles[0].Name()
+	path2 := tmpFiles[1].Name()
+
+	// an access time older than the modification time is updated even
+	// under relatime, unless O_NOATIME is used
+	old := time.Now().Add(-48 * time.Hour)
+	for _, p := range []string{path1, path2} {
+		if err := os.Chtimes(p, old, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opt := Options{NoAtime: true, DropCache: true}
+	for _, c := range []*Cmp{New(nil, opt), NewMultiple(nil, opt, sha256.New(), true)} {
+		if eq, err := c.CompareFile(path1, path2); !eq || err != nil {
+			t.Errorf("CompareFile: got %v %v expected true", eq, err)
+		}
+	}
+
+	for _, p := range []string{path1, path2} {
+		if a := atime(t, p); !a.Equal(old) {
+			t.Errorf("%s: access time changed from %v to %v", p, old, a)
+		}
+	}
+}
+
+func TestFadvise(t *testing.T) {
+	pat := "equalfiles_test_fadvise"
+	tmpFiles := makeTmpFiles(t, pat, [][]byte{[]byte("abc")})
+	defer cleanupTmpFiles(tmpFiles)
+
+	f, err := os.Open(tmpFiles[0].Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, advice := range []int{fadvSequential, fadvDontNeed} {
+		if err := fadvise(f, 0, 0, advice); err != nil {
+			t.Errorf("fadvise(%d): %v", advice, err)
+		}
+	}
+}
+
+func TestFadviseError(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	// ESPIPE, unless fadvise is a no-op on this platform
+	if err := fadvise(r, 0, 0, fadvDontNeed); err == nil {
+		t.Skip("fadvise not supported")
+	}
+
+	// reported once in debug mode
+	stdout := os.Stdout
+	os.Stdout = w
+	c := New(nil, Options{Debug: true})
+	c.adviseFailed(fadvise(r, 0, 0, fadvSequential))
+	c.adviseFailed(fadvise(r, 0, 0, fadvDontNeed))
+	c.adviseFailed(nil)
+	os.Stdout = stdout
+	w.Close()
+
+	out, _ := ioutil.ReadAll(r)
+	if n := strings.Count(string(out), "fadvise:"); n != 1 {
+		t.Errorf("expected one fadvise error reported, got %q", out)
+	}
+}
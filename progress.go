@@ -0,0 +1,155 @@
+package equalfile
+
+import (
+	"io"
+	"time"
+)
+
+// Only one progress report per second, unless Options.ProgressInterval
+// says otherwise.
+const defaultProgressInterval = time.Second
+
+// Phase identifies what a comparison is doing, as reported to
+// Options.Progress.
+type Phase int
+
+const (
+	PhaseHash1   Phase = iota + 1 // multiple mode: hashing the first input
+	PhaseHash2                    // multiple mode: hashing the second input
+	PhaseCompare                  // byte-by-byte comparison
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseHash1:
+		return "hashing file 1"
+	case PhaseHash2:
+		return "hashing file 2"
+	case PhaseCompare:
+		return "comparing"
+	}
+	return "unknown"
+}
+
+// Progress is passed to Options.Progress.
+type Progress struct {
+	Phase      Phase
+	Name       string        // input being hashed; empty when comparing
+	Done       int64         // bytes hashed, or compared, so far in this phase
+	Total      int64         // bytes expected in this phase, or -1 if unknown
+	Elapsed    time.Duration // since the phase started
+	Throughput float64       // bytes per second in this phase
+	Final      bool          // last report for this phase
+}
+
+// progress tracks the current phase for Options.Progress.
+type progress struct {
+	Progress
+	start time.Time
+	last  time.Time // of the last report
+}
+
+// startPhase begins reporting a phase. total is -1 if unknown.
+func (c *Cmp) startPhase(phase Phase, name string, total int64) {
+	if c.Opt.Progress == nil {
+		return
+	}
+	now := time.Now()
+	c.prog = progress{
+		Progress: Progress{Phase: phase, Name: name, Total: total},
+		start:    now,
+		last:     now,
+	}
+}
+
+// progressUpdate records done bytes for the current phase, reporting them
+// if the progress interval has passed.
+func (c *Cmp) progressUpdate(done int64) {
+	if c.Opt.Progress == nil || c.prog.Phase == 0 {
+		return
+	}
+	c.prog.Done = done
+
+	interval := c.Opt.ProgressInterval
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	if now := time.Now(); now.Sub(c.prog.last) >= interval {
+		c.prog.last = now
+		c.report(now)
+	}
+}
+
+// endPhase sends the final report for the current phase.
+func (c *Cmp) endPhase() {
+	if c.Opt.Progress == nil || c.prog.Phase == 0 {
+		return
+	}
+	c.prog.Final = true
+	c.report(time.Now())
+	c.prog = progress{}
+}
+
+func (c *Cmp) report(now time.Time) {
+	p := c.prog.Progress
+	p.Elapsed = now.Sub(c.prog.start)
+	if p.Elapsed > 0 {
+		p.Throughput = float64(p.Done) / p.Elapsed.Seconds()
+	}
+	c.Opt.Progress(p)
+}
+
+// progressReader reports bytes read for the current phase.
+type progressReader struct {
+	c    *Cmp
+	r    io.Reader
+	done int64
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.done += int64(n)
+	r.c.progressUpdate(r.done)
+	return n, err
+}
+
+// hashTotal returns the bytes expected when hashing r: the size of a
+// regular file, or else maxSize if set by Options.MaxSize, or -1.
+func (c *Cmp) hashTotal(r io.Reader, maxSize int64) int64 {
+	if c.Opt.Progress == nil {
+		return -1
+	}
+	total := maxSize
+	if c.Opt.MaxSize == 0 {
+		total = -1
+	}
+	if f, isFile := osFile(r); isFile {
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() && (total < 0 || info.Size() < total) {
+			total = info.Size()
+		}
+	}
+	return total
+}
+
+// compareTotal returns the bytes expected when comparing r1 and r2: the
+// largest known size, or else the limit set by MaxSize or a LimitedReader,
+// or -1.
+func (c *Cmp) compareTotal(r1, r2 io.Reader) int64 {
+	total := c.last.Size1
+	if c.last.Size2 > total {
+		total = c.last.Size2
+	}
+	if total >= 0 {
+		return total
+	}
+	if lr, isLR := r1.(*io.LimitedReader); isLR {
+		return lr.N
+	}
+	if lr, isLR := r2.(*io.LimitedReader); isLR {
+		return lr.N
+	}
+	if c.Opt.MaxSize > 0 {
+		return c.Opt.MaxSize
+	}
+	return -1
+}
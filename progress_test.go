@@ -0,0 +1,78 @@
+package equalfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProgressPhases(t *testing.T) {
+	dir, errDir := ioutil.TempDir("", "equalfile-progress")
+	if errDir != nil {
+		t.Fatal(errDir)
+	}
+	defer os.RemoveAll(dir)
+
+	data := bytes.Repeat([]byte("progress"), 10000)
+	path1 := filepath.Join(dir, "1")
+	path2 := filepath.Join(dir, "2")
+	for _, p := range []string{path1, path2} {
+		if err := ioutil.WriteFile(p, data, 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var reports []Progress
+	opt := Options{
+		Progress:         func(p Progress) { reports = append(reports, p) },
+		ProgressInterval: time.Nanosecond,
+	}
+	c := NewMultiple(make([]byte, 2000), opt, sha256.New(), true)
+	if eq, err := c.CompareFile(path1, path2); !eq || err != nil {
+		t.Fatalf("CompareFile: got %v %v expected true", eq, err)
+	}
+
+	size := int64(len(data))
+	var finals []Progress
+	for _, p := range reports {
+		if p.Done < 0 || p.Done > size || p.Total != size {
+			t.Errorf("bad report: %+v", p)
+		}
+		if p.Final {
+			finals = append(finals, p)
+		}
+	}
+	if len(reports) <= len(finals) {
+		t.Errorf("expected periodic reports, got %d reports", len(reports))
+	}
+
+	phases := []Phase{PhaseHash1, PhaseHash2, PhaseCompare}
+	if len(finals) != len(phases) {
+		t.Fatalf("expected %d final reports, got %+v", len(phases), finals)
+	}
+	for i, p := range finals {
+		if p.Phase != phases[i] || p.Done != size {
+			t.Errorf("final report %d: expected %v done=%d, got %+v", i, phases[i], size, p)
+		}
+	}
+	if finals[0].Name != path1 || finals[1].Name != path2 {
+		t.Errorf("unexpected names: %q %q", finals[0].Name, finals[1].Name)
+	}
+}
+
+func TestProgressReaderUnknownTotal(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 1000)
+
+	var last Progress
+	c := New(make([]byte, 100), Options{Progress: func(p Progress) { last = p }})
+	if eq, err := c.CompareReader(bytes.NewReader(data), bytes.NewReader(data)); !eq || err != nil {
+		t.Fatalf("CompareReader: got %v %v expected true", eq, err)
+	}
+	if !last.Final || last.Phase != PhaseCompare || last.Done != 1000 || last.Total != -1 {
+		t.Errorf("unexpected final report: %+v", last)
+	}
+}
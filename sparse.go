@@ -0,0 +1,117 @@
+package equalfile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// extent is a region [start,end) of a file.
+type extent struct {
+	start, end int64
+}
+
+// mergeExtents returns the union of two extent lists, sorted by offset.
+func mergeExtents(a, b []extent) []extent {
+	all := append(append([]extent{}, a...), b...)
+	sort.Slice(all, func(i, j int) bool { return all[i].start < all[j].start })
+
+	var merged []extent
+	for _, e := range all {
+		if n := len(merged); n > 0 && e.start <= merged[n-1].end {
+			if e.end > merged[n-1].end {
+				merged[n-1].end = e.end
+			}
+			continue
+		}
+		merged = append(merged, e)
+	}
+	return merged
+}
+
+// compareSparse compares regular files f1 and f2, both with the given size,
+// reading only regions where at least one of them has data. Holes read as
+// zeros, so regions that are holes in both files are equal without reading.
+//
+// handled is false when the filesystem can't report data extents. File
+// offsets are then rewound, so the caller can fall back to compareReader.
+func (c *Cmp) compareSparse(f1, f2 *os.File, size, maxSize int64) (handled, equal bool, err error) {
+	e1, err1 := dataExtents(f1, size)
+	e2, err2 := dataExtents(f2, size)
+	if err1 != nil || err2 != nil {
+		c.debugf("compareSparse: data extents unavailable: %v %v\n", err1, err2)
+		if _, err := f1.Seek(0, io.SeekStart); err != nil {
+			equal, err = c.resultErr(err)
+			return true, equal, err
+		}
+		if _, err := f2.Seek(0, io.SeekStart); err != nil {
+			equal, err = c.resultErr(err)
+			return true, equal, err
+		}
+		return false, false, nil
+	}
+
+	limit := size
+	if maxSize < limit {
+		limit = maxSize
+	}
+
+	half := len(c.buf) / 2
+	if half < 1 {
+		equal, err = c.resultErr(fmt.Errorf("insufficient buffer size"))
+		return true, equal, err
+	}
+	buf1 := c.buf[:half]
+	buf2 := c.buf[half : 2*half]
+
+	c.startPhase(PhaseCompare, "", limit)
+	defer c.endPhase()
+
+	extents := mergeExtents(e1, e2)
+	c.debugf("compareSparse: data extents: %d %d merged: %d\n", len(e1), len(e2), len(extents))
+
+	for _, e := range extents {
+		if e.start >= limit {
+			break
+		}
+		end := e.end
+		if end > limit {
+			end = limit
+		}
+
+		s1 := io.NewSectionReader(f1, e.start, end-e.start)
+		s2 := io.NewSectionReader(f2, e.start, end-e.start)
+
+		for off := e.start; off < end; {
+			n := int64(half)
+			if end-off < n {
+				n = end - off
+			}
+			n1, errRead1 := readPartial(c, s1, buf1, 0, int(n))
+			if errRead1 != nil && errRead1 != io.EOF {
+				equal, err = c.resultErr(errRead1)
+				return true, equal, err
+			}
+			n2, errRead2 := readPartial(c, s2, buf2, 0, int(n))
+			if errRead2 != nil && errRead2 != io.EOF {
+				equal, err = c.resultErr(errRead2)
+				return true, equal, err
+			}
+			if i := mismatchIndex(buf1[:n1], buf2[:n2]); i < int(n) {
+				c.debugf("compareSparse: found byte mismatch\n")
+				c.last.Offset = off + int64(i)
+				return true, c.result(false, ReasonContentMismatch), nil
+			}
+			off += n
+			c.progressUpdate(off)
+		}
+	}
+
+	if limit < size {
+		c.debugf("compareSparse: partial match, but max size exceeded\n")
+		return true, c.result(true, ReasonMaxSize), fmt.Errorf("max read size reached")
+	}
+
+	return true, c.result(true, ReasonContentMatch), nil
+}
@@ -0,0 +1,40 @@
+package equalfile
+
+import (
+	"os"
+	"syscall"
+)
+
+// whence values for lseek(2), missing from package syscall.
+const (
+	seekData = 3 // SEEK_DATA
+	seekHole = 4 // SEEK_HOLE
+)
+
+// dataExtents returns the regions of f within [0,size) holding data.
+// Filesystems without hole support report the whole file as data.
+func dataExtents(f *os.File, size int64) ([]extent, error) {
+	var extents []extent
+	for off := int64(0); off < size; {
+		data, err := f.Seek(off, seekData)
+		if err != nil {
+			if pe, isPathErr := err.(*os.PathError); isPathErr && pe.Err == syscall.ENXIO {
+				break // only holes after off
+			}
+			return nil, err
+		}
+		hole, err := f.Seek(data, seekHole)
+		if err != nil {
+			return nil, err
+		}
+		if hole > size {
+			hole = size
+		}
+		if data >= hole {
+			break
+		}
+		extents = append(extents, extent{data, hole})
+		off = hole
+	}
+	return extents, nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package equalfile
+
+import (
+	"fmt"
+	"os"
+)
+
+func dataExtents(f *os.File, size int64) ([]extent, error) {
+	return nil, fmt.Errorf("data extents not supported on this platform")
+}
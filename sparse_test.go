@@ -0,0 +1,70 @@
+package equalfile
+
+import (
+	"os"
+	"testing"
+)
+
+// makeSparseFile creates a file of the given size with data written at
+// the given offsets, leaving holes elsewhere where supported.
+func makeSparseFile(t *testing.T, pat string, size int64, data map[int64]string) *os.File {
+	f := makeTmpFiles(t, pat, [][]byte{nil})[0]
+	if err := f.Truncate(size); err != nil {
+		cleanupTmpFiles([]*os.File{f})
+		t.Fatal(err)
+	}
+	for off, s := range data {
+		if _, err := f.WriteAt([]byte(s), off); err != nil {
+			cleanupTmpFiles([]*os.File{f})
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+func TestCompareSparse(t *testing.T) {
+	const size = 10 * 1024 * 1024
+
+	base := makeSparseFile(t, "equalfiles_test_sparse_base", size, map[int64]string{0: "head", 5000000: "middle"})
+	same := makeSparseFile(t, "equalfiles_test_sparse_same", size, map[int64]string{0: "head", 5000000: "middle"})
+	zeros := makeSparseFile(t, "equalfiles_test_sparse_zeros", size, map[int64]string{0: "head", 5000000: "middle", 8000000: "\x00\x00\x00"})
+	other := makeSparseFile(t, "equalfiles_test_sparse_other", size, map[int64]string{0: "head", 5000000: "middle", 9000000: "x"})
+	defer cleanupTmpFiles([]*os.File{base, same, zeros, other})
+
+	var tests = []struct {
+		opt    Options
+		path   string
+		want   bool
+		offset int64
+		err    bool
+	}{
+		{opt: Options{Sparse: true}, path: same.Name(), want: true, offset: -1},
+		{opt: Options{Sparse: true}, path: zeros.Name(), want: true, offset: -1}, // explicit zeros versus hole
+		{opt: Options{Sparse: true}, path: other.Name(), want: false, offset: 9000000},
+		{opt: Options{Sparse: true, MaxSize: 6000000}, path: other.Name(), want: true, offset: -1, err: true},
+		{opt: Options{}, path: other.Name(), want: false, offset: 9000000},
+	}
+
+	for _, v := range tests {
+		c := New(nil, v.opt)
+		c.Opt.ForceFileRead = true
+		eq, err := c.CompareFile(base.Name(), v.path)
+		if eq != v.want || (err != nil) != v.err || c.LastResult().Offset != v.offset {
+			t.Errorf("CompareFile(sparse=%v,max=%d): got %v %v offset=%d expected %v err=%v offset=%d",
+				v.opt.Sparse, v.opt.MaxSize, eq, err, c.LastResult().Offset, v.want, v.err, v.offset)
+		}
+	}
+}
+
+func TestMergeExtents(t *testing.T) {
+	got := mergeExtents([]extent{{0, 10}, {50, 60}}, []extent{{5, 20}, {20, 30}, {70, 80}})
+	want := []extent{{0, 30}, {50, 60}, {70, 80}}
+	if len(got) != len(want) {
+		t.Fatalf("mergeExtents: got %v expected %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("mergeExtents: got %v expected %v", got, want)
+		}
+	}
+}
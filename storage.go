@@ -0,0 +1,170 @@
+package equalfile
+
+import (
+	"io"
+	"os"
+)
+
+// Storage provides the contents named by the path-based comparisons:
+// CompareFile, CompareFileBytes, CompareReaderFile, CompareFileLineSet,
+// CompareURL, HashFile, NewVerifyFileWriter and multiple mode hashing.
+// Names are passed as given to those methods.
+//
+// Options.Storage defaults to the local filesystem. Symbolic link
+// handling (Options.Symlinks), sparse files and extended attributes are
+// only supported by the local filesystem.
+//
+// A Storage may also implement SameFiler and Checksummer.
+type Storage interface {
+	Open(name string) (io.ReadCloser, error)
+	Stat(name string) (os.FileInfo, error)
+}
+
+// SameFiler is implemented by a Storage that can tell whether two names
+// refer to the same object, like os.SameFile. Unless Options.ForceFileRead
+// is set, such objects are reported equal without reading them.
+type SameFiler interface {
+	SameFile(info1, info2 os.FileInfo) bool
+}
+
+// Checksummer is implemented by a Storage holding precomputed checksums,
+// like a content-addressed store. In multiple mode, a checksum found for
+// a name is used instead of reading and hashing the content.
+//
+// The checksum must have been computed over the whole content with the
+// same algorithm as the hash given to NewMultiple. Checksums are not
+// used when Options.MaxSize is set, since hashes then cover only a prefix.
+type Checksummer interface {
+	Checksum(name string) (sum []byte, found bool)
+}
+
+// osStorage is the local filesystem.
+type osStorage struct {
+	noAtime   bool
+	dropCache bool
+
+	adviseFailed func(error) // reports posix_fadvise errors
+}
+
+func (s osStorage) Open(name string) (io.ReadCloser, error) {
+	f, err := openFile(name, s.noAtime)
+	if err != nil {
+		return nil, err
+	}
+	if !s.dropCache {
+		return f, nil
+	}
+	s.adviseFailed(fadvise(f, 0, 0, fadvSequential))
+	return &advisedFile{f: f, adviseFailed: s.adviseFailed}, nil
+}
+
+func (osStorage) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osStorage) SameFile(info1, info2 os.FileInfo) bool {
+	return os.SameFile(info1, info2)
+}
+
+func (c *Cmp) storage() Storage {
+	if c.Opt.Storage == nil {
+		return osStorage{noAtime: c.Opt.NoAtime, dropCache: c.Opt.DropCache, adviseFailed: c.adviseFailed}
+	}
+	return c.Opt.Storage
+}
+
+// localStorage reports whether names are paths in the local filesystem.
+func (c *Cmp) localStorage() bool {
+	_, isOS := c.storage().(osStorage)
+	return isOS
+}
+
+// openStat opens name and returns its FileInfo.
+func (c *Cmp) openStat(name string) (io.ReadCloser, os.FileInfo, error) {
+	r, openErr := c.storage().Open(name)
+	if openErr != nil {
+		return nil, nil, openErr
+	}
+
+	var info os.FileInfo
+	var statErr error
+	if f, isFile := osFile(r); isFile {
+		info, statErr = f.Stat() // stat what was actually opened
+	} else {
+		info, statErr = c.storage().Stat(name)
+	}
+	if statErr != nil {
+		r.Close()
+		return nil, nil, statErr
+	}
+
+	return r, info, nil
+}
+
+// osFile returns the *os.File behind r, if any.
+func osFile(r io.Reader) (*os.File, bool) {
+	switch f := r.(type) {
+	case *os.File:
+		return f, true
+	case *advisedFile:
+		return f.f, true
+	}
+	return nil, false
+}
+
+// Pages are dropped from the page cache every dropChunk bytes read.
+const dropChunk = 8 * 1024 * 1024
+
+// advisedFile drops pages behind the read cursor from the page cache
+// (Options.DropCache).
+type advisedFile struct {
+	f       *os.File
+	pos     int64 // bytes read
+	dropped int64 // pages before this offset were dropped
+
+	adviseFailed func(error)
+}
+
+func (a *advisedFile) Read(p []byte) (int, error) {
+	n, err := a.f.Read(p)
+	a.pos += int64(n)
+	if a.pos-a.dropped >= dropChunk {
+		a.adviseFailed(fadvise(a.f, a.dropped, a.pos-a.dropped, fadvDontNeed))
+		a.dropped = a.pos
+	}
+	return n, err
+}
+
+func (a *advisedFile) Close() error {
+	// also drops pages read ahead, or read through ReadAt by sparse comparison
+	a.adviseFailed(fadvise(a.f, a.dropped, 0, fadvDontNeed))
+	return a.f.Close()
+}
+
+// adviseFailed reports the first posix_fadvise error in debug mode. Errors
+// are otherwise ignored, since advice only affects performance.
+func (c *Cmp) adviseFailed(err error) {
+	if err == nil || c.adviseWarned {
+		return
+	}
+	c.adviseWarned = true
+	c.debugf("fadvise: %v: DropCache may have no effect\n", err)
+}
+
+func (c *Cmp) sameFile(info1, info2 os.FileInfo) bool {
+	s, ok := c.storage().(SameFiler)
+	return ok && s.SameFile(info1, info2)
+}
+
+// precomputedHash returns the checksum the storage holds for name, if any.
+func (c *Cmp) precomputedHash(name string) ([]byte, bool) {
+	s, ok := c.storage().(Checksummer)
+	if !ok || c.Opt.MaxSize != 0 {
+		return nil, false
+	}
+	sum, found := s.Checksum(name)
+	if !found || len(sum) != c.hashType.Size() {
+		return nil, false
+	}
+	return sum, true
+}
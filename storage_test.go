@@ -0,0 +1,127 @@
+package equalfile
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memStorage is a Storage keeping contents in memory. Objects with the
+// same id are the same object, and sums are precomputed checksums.
+type memStorage struct {
+	contents map[string]string
+	ids      map[string]string
+	sums     map[string][]byte
+	opens    map[string]int
+}
+
+type memInfo struct {
+	name string
+	size int64
+	id   string
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) Mode() os.FileMode  { return 0644 }
+func (i memInfo) ModTime() time.Time { return time.Time{} }
+func (i memInfo) IsDir() bool        { return false }
+func (i memInfo) Sys() interface{}   { return nil }
+
+func (s *memStorage) Open(name string) (io.ReadCloser, error) {
+	s.opens[name]++
+	content, found := s.contents[name]
+	if !found {
+		return nil, fmt.Errorf("%s: not found", name)
+	}
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+func (s *memStorage) Stat(name string) (os.FileInfo, error) {
+	content, found := s.contents[name]
+	if !found {
+		return nil, fmt.Errorf("%s: not found", name)
+	}
+	return memInfo{name: name, size: int64(len(content)), id: s.ids[name]}, nil
+}
+
+func (s *memStorage) SameFile(info1, info2 os.FileInfo) bool {
+	id1 := info1.(memInfo).id
+	return id1 != "" && id1 == info2.(memInfo).id
+}
+
+func (s *memStorage) Checksum(name string) ([]byte, bool) {
+	sum, found := s.sums[name]
+	return sum, found
+}
+
+func newMemStorage() *memStorage {
+	sum := sha256.Sum256([]byte("hello"))
+	return &memStorage{
+		contents: map[string]string{"a": "hello", "b": "hello", "c": "hellx", "d": "hi", "e": "hello", "f": "hello"},
+		ids:      map[string]string{"e": "x", "f": "x"},
+		sums:     map[string][]byte{"a": sum[:], "b": sum[:]},
+		opens:    map[string]int{},
+	}
+}
+
+func TestStorage(t *testing.T) {
+	var tests = []struct {
+		name1, name2 string
+		want         bool
+		reason       string
+	}{
+		{name1: "a", name2: "b", want: true, reason: ReasonContentMatch},
+		{name1: "a", name2: "c", want: false, reason: ReasonContentMismatch},
+		{name1: "a", name2: "d", want: false, reason: ReasonSizeMismatch},
+		{name1: "e", name2: "f", want: true, reason: ReasonSameFile},
+	}
+
+	s := newMemStorage()
+	c := New(nil, Options{Storage: s})
+	for _, v := range tests {
+		eq, err := c.CompareFile(v.name1, v.name2)
+		if eq != v.want || err != nil || c.LastResult().Reason != v.reason {
+			t.Errorf("CompareFile(%s,%s): got %v %v %q expected %v %q", v.name1, v.name2, eq, err, c.LastResult().Reason, v.want, v.reason)
+		}
+	}
+
+	if _, err := c.CompareFile("a", "missing"); err == nil {
+		t.Errorf("CompareFile: expected error for missing object")
+	}
+
+	if eq, err := c.CompareFileString("c", "hellx"); !eq || err != nil {
+		t.Errorf("CompareFileString: got %v %v expected true", eq, err)
+	}
+}
+
+func TestStorageChecksum(t *testing.T) {
+	s := newMemStorage()
+	c := NewMultiple(nil, Options{Storage: s}, sha256.New(), false)
+
+	if eq, err := c.CompareFile("a", "b"); !eq || err != nil || c.LastResult().Reason != ReasonHashMatch {
+		t.Errorf("CompareFile(a,b): got %v %v %q expected hash match", eq, err, c.LastResult().Reason)
+	}
+	if s.opens["a"] != 1 || s.opens["b"] != 1 {
+		t.Errorf("precomputed checksums: a opened %d times, b opened %d times, expected once (by CompareFile only)", s.opens["a"], s.opens["b"])
+	}
+
+	if eq, err := c.CompareFile("a", "c"); eq || err != nil || c.LastResult().Reason != ReasonHashMismatch {
+		t.Errorf("CompareFile(a,c): got %v %v %q expected hash mismatch", eq, err, c.LastResult().Reason)
+	}
+	c.CompareFile("c", "e")
+	if s.opens["c"] != 3 {
+		// opened by each CompareFile, hashed only once
+		t.Errorf("c opened %d times, expected 3", s.opens["c"])
+	}
+
+	sum, err := c.HashFile("b")
+	if want := sha256.Sum256([]byte("hello")); err != nil || string(sum) != string(want[:]) {
+		t.Errorf("HashFile(b): got %x %v", sum, err)
+	}
+}
@@ -0,0 +1,95 @@
+package equalfile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// SymlinkMode controls how CompareFile handles symbolic links.
+type SymlinkMode int
+
+const (
+	// SymlinkFollow follows symbolic links and compares the files they
+	// point to. This is the default.
+	SymlinkFollow SymlinkMode = iota
+
+	// SymlinkCompareTarget does not follow symbolic links. Two links are
+	// equal if their targets (as returned by os.Readlink) are the same
+	// string. A link versus a non-link is a type mismatch.
+	SymlinkCompareTarget
+
+	// SymlinkTypeMismatch reports a link versus a non-link as a type
+	// mismatch. Two links are followed and their contents compared.
+	SymlinkTypeMismatch
+)
+
+// ReasonTypeMismatch and friends are reported in Result for symbolic links.
+const (
+	ReasonTypeMismatch   = "type mismatch"        // symbolic link versus non-link
+	ReasonTargetMatch    = "link target match"    // links point to the same target
+	ReasonTargetMismatch = "link target mismatch" // links point to distinct targets
+)
+
+// compareSymlinks applies Opt.Symlinks to path1 and path2. It returns
+// done=true when the comparison was decided without looking at contents.
+func (c *Cmp) compareSymlinks(path1, path2 string) (done, equal bool, err error) {
+	if c.Opt.Symlinks == SymlinkFollow || !c.localStorage() {
+		return false, false, nil
+	}
+
+	info1, lstatErr1 := os.Lstat(path1)
+	if lstatErr1 != nil {
+		equal, err = c.resultErr(lstatErr1)
+		return true, equal, err
+	}
+	info2, lstatErr2 := os.Lstat(path2)
+	if lstatErr2 != nil {
+		equal, err = c.resultErr(lstatErr2)
+		return true, equal, err
+	}
+
+	link1 := info1.Mode()&os.ModeSymlink != 0
+	link2 := info2.Mode()&os.ModeSymlink != 0
+
+	switch {
+	case link1 != link2:
+		c.resetResult(info1.Size(), info2.Size())
+		c.debugf("CompareFile(%s,%s): symlink versus non-symlink\n", path1, path2)
+		return true, c.result(false, ReasonTypeMismatch), nil
+	case !link1:
+		return false, false, nil // neither is a link
+	case c.Opt.Symlinks != SymlinkCompareTarget:
+		return false, false, nil // follow both links
+	}
+
+	target1, readErr1 := os.Readlink(path1)
+	if readErr1 != nil {
+		equal, err = c.resultErr(readErr1)
+		return true, equal, err
+	}
+	target2, readErr2 := os.Readlink(path2)
+	if readErr2 != nil {
+		equal, err = c.resultErr(readErr2)
+		return true, equal, err
+	}
+
+	c.resetResult(int64(len(target1)), int64(len(target2)))
+	if target1 != target2 {
+		c.debugf("CompareFile(%s,%s): distinct link targets\n", path1, path2)
+		return true, c.result(false, ReasonTargetMismatch), nil
+	}
+	return true, c.result(true, ReasonTargetMatch), nil
+}
+
+// hashKey returns the hash table key for path. When following links, the
+// key is the resolved path, so a link and its target share a cache entry.
+func (c *Cmp) hashKey(path string) string {
+	if c.Opt.Symlinks == SymlinkCompareTarget || !c.localStorage() {
+		return path
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return path
+	}
+	return resolved
+}
@@ -0,0 +1,83 @@
+package equalfile
+
+import (
+	"sync"
+	"time"
+)
+
+// Reading may catch up this far behind schedule.
+const throttleSlack = 100 * time.Millisecond
+
+// Limiter paces reads to a number of bytes and of reads per second.
+//
+// A Cmp is not safe for concurrent use, but a Limiter is: comparisons
+// running in several goroutines, each with its own Cmp, share the limits
+// when their Options set the same Limiter.
+type Limiter struct {
+	bandwidth int64 // bytes per second, unlimited if zero
+	iops      int   // reads per second, unlimited if zero
+
+	mu        sync.Mutex
+	nextOp    time.Time // earliest start of the next read
+	nextBytes time.Time // when bytes read so far are paid for
+}
+
+// NewLimiter creates a Limiter for bandwidth bytes per second and iops
+// reads per second. Zero means unlimited.
+func NewLimiter(bandwidth int64, iops int) *Limiter {
+	return &Limiter{bandwidth: bandwidth, iops: iops}
+}
+
+// limiter returns Opt.Limiter, or else the Cmp own limiter for
+// Opt.BandwidthLimit and Opt.IOPSLimit.
+func (c *Cmp) limiter() *Limiter {
+	if c.Opt.Limiter != nil {
+		return c.Opt.Limiter
+	}
+	c.throttle.bandwidth = c.Opt.BandwidthLimit
+	c.throttle.iops = c.Opt.IOPSLimit
+	return &c.throttle
+}
+
+// wait blocks until one more read is allowed.
+func (t *Limiter) wait() {
+	if t.bandwidth < 1 && t.iops < 1 {
+		return
+	}
+
+	t.mu.Lock()
+	now := time.Now()
+	start := now
+	if t.nextOp.After(start) {
+		start = t.nextOp
+	}
+	if t.nextBytes.After(start) {
+		start = t.nextBytes
+	}
+	if t.iops > 0 {
+		t.nextOp = start.Add(time.Second / time.Duration(t.iops))
+	}
+	t.mu.Unlock()
+
+	time.Sleep(start.Sub(now))
+}
+
+// charge accounts for n bytes just read.
+func (t *Limiter) charge(n int) {
+	if t.bandwidth < 1 || n < 1 {
+		return
+	}
+
+	t.mu.Lock()
+	now := time.Now()
+	// Sleeps overshoot, so small reads would fall well short of the limit
+	// if lateness were forgotten. Only lateness beyond throttleSlack, as
+	// after an idle period, is forgiven.
+	if t.nextBytes.IsZero() {
+		t.nextBytes = now
+	} else if earliest := now.Add(-throttleSlack); t.nextBytes.Before(earliest) {
+		t.nextBytes = earliest
+	}
+	t.nextBytes = t.nextBytes.Add(time.Duration(float64(n) / float64(t.bandwidth) * float64(time.Second)))
+	t.mu.Unlock()
+}
@@ -0,0 +1,105 @@
+package equalfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestThrottleBandwidth(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 100000)
+
+	// 200KB read at 1MB/s
+	c := New(nil, Options{BandwidthLimit: 1000000})
+	begin := time.Now()
+	if eq, err := c.CompareReader(bytes.NewReader(data), bytes.NewReader(data)); !eq || err != nil {
+		t.Errorf("CompareReader: got %v %v expected true", eq, err)
+	}
+	if elapsed := time.Since(begin); elapsed < 150*time.Millisecond {
+		t.Errorf("CompareReader: took %v, expected about 200ms", elapsed)
+	}
+}
+
+func TestThrottleSmallReads(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 1000000)
+
+	// 2MB read at 10MB/s, 100 bytes at a time: each read pays for 10us,
+	// far below sleep resolution
+	c := New(make([]byte, 200), Options{BandwidthLimit: 10000000})
+	begin := time.Now()
+	if eq, err := c.CompareReader(bytes.NewReader(data), bytes.NewReader(data)); !eq || err != nil {
+		t.Errorf("CompareReader: got %v %v expected true", eq, err)
+	}
+	if elapsed := time.Since(begin); elapsed < 150*time.Millisecond || elapsed > time.Second {
+		t.Errorf("CompareReader: took %v, expected about 200ms", elapsed)
+	}
+}
+
+func TestThrottleIOPS(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 100)
+
+	// 20 reads of 10 bytes at 100 reads/s, at least
+	c := New(make([]byte, 20), Options{IOPSLimit: 100})
+	begin := time.Now()
+	if eq, err := c.CompareReader(bytes.NewReader(data), bytes.NewReader(data)); !eq || err != nil {
+		t.Errorf("CompareReader: got %v %v expected true", eq, err)
+	}
+	if elapsed := time.Since(begin); elapsed < 150*time.Millisecond {
+		t.Errorf("CompareReader: took %v, expected about 200ms", elapsed)
+	}
+}
+
+func TestThrottleConcurrent(t *testing.T) {
+	// 4 comparisons of 2x25KB sharing 1MB/s, each with its own Cmp
+	data := bytes.Repeat([]byte("x"), 25000)
+	opt := Options{Limiter: NewLimiter(1000000, 0)}
+	var wg sync.WaitGroup
+	begin := time.Now()
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := New(nil, opt)
+			if eq, err := c.CompareReader(bytes.NewReader(data), bytes.NewReader(data)); !eq || err != nil {
+				t.Errorf("CompareReader: got %v %v expected true", eq, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(begin); elapsed < 150*time.Millisecond {
+		t.Errorf("shared limiter: took %v, expected about 200ms", elapsed)
+	}
+}
+
+func TestThrottleLimiterOverridesOptions(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 100000)
+
+	// the Limiter is unlimited
+	c := New(nil, Options{BandwidthLimit: 1000, Limiter: NewLimiter(0, 0)})
+	begin := time.Now()
+	if eq, err := c.CompareReader(bytes.NewReader(data), bytes.NewReader(data)); !eq || err != nil {
+		t.Errorf("CompareReader: got %v %v expected true", eq, err)
+	}
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("CompareReader: took %v, expected no limit", elapsed)
+	}
+}
+
+func TestThrottleHashing(t *testing.T) {
+	pat := "equalfiles_test_throttle"
+	data := bytes.Repeat([]byte("x"), 50000)
+	tmpFiles := makeTmpFiles(t, pat, [][]byte{data, data})
+	defer cleanupTmpFiles(tmpFiles)
+
+	// hashing 2 files of 50KB at 1MB/s
+	c := NewMultiple(nil, Options{BandwidthLimit: 1000000}, sha256.New(), false)
+	begin := time.Now()
+	if eq, err := c.CompareFile(tmpFiles[0].Name(), tmpFiles[1].Name()); !eq || err != nil || c.LastResult().Reason != ReasonHashMatch {
+		t.Errorf("CompareFile: got %v %v %q expected hash match", eq, err, c.LastResult().Reason)
+	}
+	if elapsed := time.Since(begin); elapsed < 70*time.Millisecond {
+		t.Errorf("CompareFile: took %v, expected about 100ms", elapsed)
+	}
+}
@@ -0,0 +1,190 @@
+package equalfile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Reasons reported in Result by CompareURL and CompareURLs with
+// Options.HTTPShallow.
+const (
+	ReasonETagMatch         = "etag match"          // both responses have the same strong ETag
+	ReasonLastModifiedMatch = "last-modified match" // same Last-Modified and size
+)
+
+// CompareURL verifies that the content served at url is the same as the
+// content of file path. The response body is streamed, never buffered.
+//
+// A Content-Length distinct from the size of a regular file is reported
+// as a difference without reading. With Opt.HTTPShallow, a Last-Modified
+// header equal to the file modification time, along with equal sizes, is
+// trusted as a match. Non-2xx statuses are errors. Requests are made with
+// client, or http.DefaultClient if nil.
+// Details about the comparison are available from LastResult.
+func (c *Cmp) CompareURL(ctx context.Context, client *http.Client, url, path string) (bool, error) {
+
+	c.resetResult(-1, -1)
+
+	if c.Opt.MaxSize < 0 {
+		return c.resultErr(fmt.Errorf("negative MaxSize"))
+	}
+
+	f, info, openErr := c.openStat(path)
+	if openErr != nil {
+		return c.resultErr(openErr)
+	}
+	defer f.Close()
+
+	resp, getErr := httpGet(ctx, client, url)
+	if getErr != nil {
+		return c.resultErr(getErr)
+	}
+	defer resp.Body.Close()
+
+	size := resp.ContentLength
+
+	c.resetResult(size, info.Size())
+
+	if size >= 0 && info.Mode().IsRegular() {
+		if size != info.Size() {
+			c.debugf("CompareURL(%s,%s): distinct sizes\n", url, path)
+			return c.result(false, ReasonSizeMismatch), nil
+		}
+		if c.Opt.HTTPShallow && sameModTime(resp, info.ModTime()) {
+			c.debugf("CompareURL(%s,%s): Last-Modified matches file modification time\n", url, path)
+			return c.result(true, ReasonLastModifiedMatch), nil
+		}
+	}
+
+	maxSize := c.Opt.MaxSize
+	if maxSize == 0 {
+		// Like CompareReaderFile: the body may be longer than the file
+		// when Content-Length is unknown.
+		maxSize = info.Size()
+		if size < 0 && maxSize < defaultMaxSize {
+			maxSize = defaultMaxSize
+		}
+		if maxSize == 0 {
+			maxSize = defaultMaxSize
+		}
+	}
+
+	c.resetDebugging()
+
+	eq, err := c.compareReader(resp.Body, f, maxSize)
+
+	c.printDebugCompareReader()
+
+	return eq, err
+}
+
+// CompareURLs verifies that the contents served at url1 and url2 are the
+// same. Both response bodies are streamed, never buffered.
+//
+// Distinct Content-Length headers are reported as a difference without
+// reading. With Opt.HTTPShallow, equal strong ETags, or equal Last-Modified
+// and Content-Length headers, are trusted as a match; this is only sound
+// when both servers derive these headers from the same origin. Non-2xx
+// statuses are errors. Requests are made with client, or
+// http.DefaultClient if nil.
+// Details about the comparison are available from LastResult.
+func (c *Cmp) CompareURLs(ctx context.Context, client *http.Client, url1, url2 string) (bool, error) {
+
+	c.resetResult(-1, -1)
+
+	if c.Opt.MaxSize < 0 {
+		return c.resultErr(fmt.Errorf("negative MaxSize"))
+	}
+
+	resp1, getErr1 := httpGet(ctx, client, url1)
+	if getErr1 != nil {
+		return c.resultErr(getErr1)
+	}
+	defer resp1.Body.Close()
+
+	resp2, getErr2 := httpGet(ctx, client, url2)
+	if getErr2 != nil {
+		return c.resultErr(getErr2)
+	}
+	defer resp2.Body.Close()
+
+	size1 := resp1.ContentLength
+	size2 := resp2.ContentLength
+
+	c.resetResult(size1, size2)
+
+	if size1 >= 0 && size2 >= 0 && size1 != size2 {
+		c.debugf("CompareURLs(%s,%s): distinct sizes\n", url1, url2)
+		return c.result(false, ReasonSizeMismatch), nil
+	}
+
+	if c.Opt.HTTPShallow {
+		if etag := resp1.Header.Get("ETag"); isStrongETag(etag) && etag == resp2.Header.Get("ETag") {
+			c.debugf("CompareURLs(%s,%s): same ETag %s\n", url1, url2, etag)
+			return c.result(true, ReasonETagMatch), nil
+		}
+		if size1 >= 0 && size2 >= 0 {
+			if t, err := http.ParseTime(resp1.Header.Get("Last-Modified")); err == nil && sameModTime(resp2, t) {
+				c.debugf("CompareURLs(%s,%s): same Last-Modified\n", url1, url2)
+				return c.result(true, ReasonLastModifiedMatch), nil
+			}
+		}
+	}
+
+	maxSize := c.Opt.MaxSize
+	if maxSize == 0 {
+		maxSize = defaultMaxSize
+	}
+
+	c.resetDebugging()
+
+	eq, err := c.compareReader(resp1.Body, resp2.Body, maxSize)
+
+	c.printDebugCompareReader()
+
+	return eq, err
+}
+
+// httpGet fetches url, failing on non-2xx statuses.
+func httpGet(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096)) // allow connection reuse
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	return resp, nil
+}
+
+// sameModTime reports whether the Last-Modified header of resp is t,
+// at the one second resolution of HTTP dates.
+func sameModTime(resp *http.Response, t time.Time) bool {
+	lastModified, err := http.ParseTime(resp.Header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return lastModified.Equal(t.Truncate(time.Second))
+}
+
+// isStrongETag reports whether etag is a non-empty strong entity tag.
+// Weak tags (W/"...") only denote semantic equivalence.
+func isStrongETag(etag string) bool {
+	return len(etag) > 1 && etag[0] == '"'
+}
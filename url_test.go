@@ -0,0 +1,141 @@
+package equalfile
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newContentServer(t *testing.T, modTime time.Time) *httptest.Server {
+	content := map[string]string{
+		"/abc":   "abc",
+		"/abc2":  "abc",
+		"/abx":   "abx",
+		"/abcd":  "abcd",
+		"/weak":  "abc",
+		"/weak2": "xyz",
+	}
+	etags := map[string]string{
+		"/abc":   `"v1"`,
+		"/abc2":  `"v1"`,
+		"/abx":   `"v2"`,
+		"/weak":  `W/"w"`,
+		"/weak2": `W/"w"`,
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, found := content[r.URL.Path]
+		switch {
+		case r.URL.Path == "/chunked":
+			// no Content-Length
+			w.Write([]byte("ab"))
+			w.(http.Flusher).Flush()
+			w.Write([]byte("c"))
+			return
+		case r.URL.Path == "/stale":
+			// lies about content, to detect shallow checks
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+			w.Write([]byte("xyz"))
+			return
+		case !found:
+			http.NotFound(w, r)
+			return
+		}
+		if etag, ok := etags[r.URL.Path]; ok {
+			w.Header().Set("ETag", etag)
+		}
+		w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+		w.Write([]byte(body))
+	}))
+}
+
+func TestCompareURL(t *testing.T) {
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	srv := newContentServer(t, modTime)
+	defer srv.Close()
+
+	f, err := ioutil.TempFile("", "equalfile_test_url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("abc")
+	f.Close()
+	if err := os.Chtimes(f.Name(), modTime, modTime.Add(500*time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		path    string
+		shallow bool
+		want    bool
+		reason  string
+		errMsg  string
+	}{
+		{path: "/abc", want: true, reason: ReasonContentMatch},
+		{path: "/abx", want: false, reason: ReasonContentMismatch},
+		{path: "/abcd", want: false, reason: ReasonSizeMismatch},
+		{path: "/chunked", want: true, reason: ReasonContentMatch},
+		{path: "/stale", want: false, reason: ReasonContentMismatch},
+		{path: "/stale", shallow: true, want: true, reason: ReasonLastModifiedMatch},
+		{path: "/missing", errMsg: "404"},
+	}
+
+	ctx := context.Background()
+	for _, v := range tests {
+		c := New(nil, Options{HTTPShallow: v.shallow})
+		eq, err := c.CompareURL(ctx, srv.Client(), srv.URL+v.path, f.Name())
+		if v.errMsg != "" {
+			if err == nil || !strings.Contains(err.Error(), v.errMsg) {
+				t.Errorf("CompareURL(%s): got error %v expected %q", v.path, err, v.errMsg)
+			}
+			continue
+		}
+		r := c.LastResult()
+		if eq != v.want || err != nil || r.Reason != v.reason {
+			t.Errorf("CompareURL(%s) shallow=%v: got %v %v %q expected %v %q", v.path, v.shallow, eq, err, r.Reason, v.want, v.reason)
+		}
+	}
+}
+
+func TestCompareURLs(t *testing.T) {
+	srv := newContentServer(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	defer srv.Close()
+
+	var tests = []struct {
+		path1, path2 string
+		shallow      bool
+		want         bool
+		reason       string
+	}{
+		{path1: "/abc", path2: "/abc2", want: true, reason: ReasonContentMatch},
+		{path1: "/abc", path2: "/abc2", shallow: true, want: true, reason: ReasonETagMatch},
+		{path1: "/abc", path2: "/abx", want: false, reason: ReasonContentMismatch},
+		{path1: "/abc", path2: "/abcd", want: false, reason: ReasonSizeMismatch},
+		{path1: "/abc", path2: "/chunked", shallow: true, want: true, reason: ReasonContentMatch},
+		{path1: "/abc", path2: "/stale", shallow: true, want: true, reason: ReasonETagMatch},
+		{path1: "/weak", path2: "/weak2", shallow: true, want: true, reason: ReasonLastModifiedMatch},
+	}
+
+	ctx := context.Background()
+	for _, v := range tests {
+		c := New(nil, Options{HTTPShallow: v.shallow})
+		eq, err := c.CompareURLs(ctx, srv.Client(), srv.URL+v.path1, srv.URL+v.path2)
+		r := c.LastResult()
+		if eq != v.want || err != nil || r.Reason != v.reason {
+			t.Errorf("CompareURLs(%s,%s) shallow=%v: got %v %v %q expected %v %q", v.path1, v.path2, v.shallow, eq, err, r.Reason, v.want, v.reason)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := New(nil, Options{}).CompareURLs(ctx, nil, srv.URL+"/abc", srv.URL+"/abc2"); err == nil {
+		t.Errorf("CompareURLs: expected error from canceled context")
+	}
+}
@@ -0,0 +1,167 @@
+package equalfile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrMismatch is returned by VerifyWriter.Close when the written content
+// differs from the reference.
+var ErrMismatch = errors.New("content mismatch")
+
+// VerifyWriter compares bytes written to it against a reference reader,
+// without buffering them. Create it with Cmp.NewVerifyWriter.
+type VerifyWriter struct {
+	c      *Cmp
+	ref    io.Reader
+	closer io.Closer // reference opened by NewVerifyFileWriter
+
+	written  int64 // bytes written so far
+	refRead  int64 // bytes read from reference so far
+	refEOF   bool
+	mismatch int64 // offset of the first difference, or -1
+	err      error // reference read error
+	closed   bool
+
+	last Result
+}
+
+// NewVerifyWriter returns a writer that compares everything written to it
+// against reference, as the bytes arrive. Close reports whether the whole
+// content matched, including length: it returns nil if so, ErrMismatch if
+// not, or the error found reading reference. Details, like the offset of
+// the first difference, are available from Result after Close, and from
+// Cmp.LastResult.
+//
+// Writes keep succeeding after a difference is found, so the producer can
+// finish normally. MaxSize is not applied. The writer uses the Cmp buffer,
+// so the Cmp must not be used concurrently with it.
+func (c *Cmp) NewVerifyWriter(reference io.Reader) *VerifyWriter {
+	return &VerifyWriter{c: c, ref: reference, mismatch: -1}
+}
+
+// NewVerifyFileWriter is like NewVerifyWriter, for the reference file with
+// name path. The file is closed by Close.
+func (c *Cmp) NewVerifyFileWriter(path string) (*VerifyWriter, error) {
+	f, err := c.storage().Open(path)
+	if err != nil {
+		return nil, err
+	}
+	w := c.NewVerifyWriter(f)
+	w.closer = f
+	return w, nil
+}
+
+// Write compares p against the next len(p) bytes of the reference.
+// It fails only if reading the reference fails, or after Close.
+func (w *VerifyWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, fmt.Errorf("write on closed VerifyWriter")
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	buf := w.c.buf
+	ref := &cmpReader{c: w.c, r: w.ref}
+
+	var n int
+	for n < len(p) && w.mismatch < 0 {
+		chunk := p[n:]
+		if len(chunk) > len(buf) {
+			chunk = chunk[:len(buf)]
+		}
+
+		m, errRead := io.ReadFull(ref, buf[:len(chunk)])
+		w.refRead += int64(m)
+		switch errRead {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			w.refEOF = true
+		default:
+			w.err = errRead
+			w.written += int64(n)
+			return n, errRead
+		}
+
+		if i := mismatchIndex(chunk, buf[:m]); i < len(chunk) {
+			w.mismatch = w.written + int64(n+i)
+			w.c.debugf("VerifyWriter: mismatch at offset %d\n", w.mismatch)
+		}
+
+		n += len(chunk)
+	}
+
+	w.written += int64(len(p))
+
+	return len(p), nil
+}
+
+// Close finishes the comparison, checking that the reference has no more
+// data than was written. It returns nil if the content matched, ErrMismatch
+// if it did not, or the error found reading the reference.
+func (w *VerifyWriter) Close() error {
+	if w.closed {
+		return fmt.Errorf("VerifyWriter already closed")
+	}
+	w.closed = true
+
+	if w.closer != nil {
+		defer w.closer.Close()
+	}
+
+	w.last = Result{Offset: w.mismatch, Size1: w.written, Size2: -1}
+
+	if w.err == nil && w.mismatch < 0 && !w.refEOF {
+		// reference must end exactly here
+		var one [1]byte
+		m, errRead := io.ReadFull(&cmpReader{c: w.c, r: w.ref}, one[:])
+		w.refRead += int64(m)
+		switch {
+		case m > 0:
+			w.mismatch = w.written
+		case errRead == io.EOF:
+			w.refEOF = true
+		default:
+			w.err = errRead
+		}
+	}
+
+	if w.refEOF {
+		w.last.Size2 = w.refRead
+	}
+
+	var err error
+	switch {
+	case w.err != nil:
+		w.setResult(false, ReasonError)
+		err = w.err
+	case w.mismatch < 0:
+		w.setResult(true, ReasonContentMatch)
+	case w.mismatch == w.refRead || w.mismatch == w.written:
+		// one side is a prefix of the other
+		w.last.Offset = w.mismatch
+		w.setResult(false, ReasonLengthMismatch)
+		err = ErrMismatch
+	default:
+		w.last.Offset = w.mismatch
+		w.setResult(false, ReasonContentMismatch)
+		err = ErrMismatch
+	}
+
+	w.c.last = w.last
+
+	return err
+}
+
+func (w *VerifyWriter) setResult(equal bool, reason string) {
+	w.last.Equal = equal
+	w.last.ContentEqual = equal
+	w.last.Reason = reason
+}
+
+// Result describes the comparison. It is only meaningful after Close.
+func (w *VerifyWriter) Result() Result {
+	return w.last
+}
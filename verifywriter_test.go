@@ -0,0 +1,71 @@
+package equalfile
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestVerifyWriter(t *testing.T) {
+	var tests = []struct {
+		ref    string
+		writes []string
+		err    error
+		reason string
+		offset int64
+	}{
+		{ref: "", writes: nil, err: nil, reason: ReasonContentMatch, offset: -1},
+		{ref: "abcdef", writes: []string{"abc", "def"}, err: nil, reason: ReasonContentMatch, offset: -1},
+		{ref: "abcdef", writes: []string{"a", "", "bcdef"}, err: nil, reason: ReasonContentMatch, offset: -1},
+		{ref: "abcdef", writes: []string{"abc", "dxf"}, err: ErrMismatch, reason: ReasonContentMismatch, offset: 4},
+		{ref: "abcdef", writes: []string{"abc"}, err: ErrMismatch, reason: ReasonLengthMismatch, offset: 3},
+		{ref: "abc", writes: []string{"ab", "cdef"}, err: ErrMismatch, reason: ReasonLengthMismatch, offset: 3},
+		{ref: "abc", writes: []string{"ax", "cdef"}, err: ErrMismatch, reason: ReasonContentMismatch, offset: 1},
+	}
+
+	// tiny buffer exercises writes spanning several reads
+	c := New(make([]byte, 2), Options{})
+
+	for _, v := range tests {
+		w := c.NewVerifyWriter(strings.NewReader(v.ref))
+		size := 0
+		for _, s := range v.writes {
+			n, err := io.WriteString(w, s)
+			if n != len(s) || err != nil {
+				t.Errorf("ref=%q Write(%q): got %d %v", v.ref, s, n, err)
+			}
+			size += len(s)
+		}
+		err := w.Close()
+		r := w.Result()
+		if err != v.err || r.Reason != v.reason || r.Offset != v.offset || r.Size1 != int64(size) {
+			t.Errorf("ref=%q writes=%q: got %v %q offset=%d size1=%d expected %v %q offset=%d size1=%d",
+				v.ref, v.writes, err, r.Reason, r.Offset, r.Size1, v.err, v.reason, v.offset, size)
+		}
+		if c.LastResult().Reason != r.Reason {
+			t.Errorf("ref=%q writes=%q: LastResult %q differs from Result %q", v.ref, v.writes, c.LastResult().Reason, r.Reason)
+		}
+	}
+}
+
+func TestVerifyFileWriter(t *testing.T) {
+	pat := "equalfiles_test_verifywriter"
+	contents := [][]byte{[]byte("hello world\n")}
+	tmpFiles := makeTmpFiles(t, pat, contents)
+	defer cleanupTmpFiles(tmpFiles)
+
+	c := New(nil, Options{})
+	w, err := c.NewVerifyFileWriter(tmpFiles[0].Name())
+	if err != nil {
+		t.Fatalf("NewVerifyFileWriter: %v", err)
+	}
+	if _, err := io.Copy(w, strings.NewReader("hello world\n")); err != nil {
+		t.Errorf("Copy: %v", err)
+	}
+	if err := w.Close(); err != nil || !w.Result().Equal || w.Result().Size2 != 12 {
+		t.Errorf("Close: got %v %+v expected match", err, w.Result())
+	}
+	if _, err := w.Write([]byte("x")); err == nil {
+		t.Errorf("Write after Close: expected error")
+	}
+}
@@ -0,0 +1,99 @@
+package equalfile
+
+import (
+	"bytes"
+	"syscall"
+	"unsafe"
+)
+
+// fileXattrs returns the extended attributes of path as a map from name to
+// value. Symbolic links are followed only if follow is set.
+func fileXattrs(path string, follow bool) (map[string]string, error) {
+	list, get := syscall.Listxattr, syscall.Getxattr
+	if !follow {
+		list, get = llistxattr, lgetxattr
+	}
+
+	names, err := xattrRead(func(dest []byte) (int, error) { return list(path, dest) })
+	if err != nil {
+		return nil, err
+	}
+
+	attrs := map[string]string{}
+	for _, name := range bytes.Split(names, []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		n := string(name)
+		value, errGet := xattrRead(func(dest []byte) (int, error) { return get(path, n, dest) })
+		if errGet != nil {
+			return nil, errGet
+		}
+		attrs[n] = string(value)
+	}
+
+	return attrs, nil
+}
+
+// xattrRead calls get first to learn the size, then to fetch the data,
+// retrying if the attribute grew in between.
+func xattrRead(get func(dest []byte) (int, error)) ([]byte, error) {
+	for {
+		size, err := get(nil)
+		if err == syscall.ENOTSUP {
+			return nil, nil // filesystem without xattr support
+		}
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size)
+		n, err := get(buf)
+		if err == syscall.ERANGE {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+// llistxattr and lgetxattr are missing from package syscall.
+
+func llistxattr(path string, dest []byte) (int, error) {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	n, _, errno := syscall.Syscall(syscall.SYS_LLISTXATTR, uintptr(unsafe.Pointer(p)), uintptr(bufPtr(dest)), uintptr(len(dest)))
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+func lgetxattr(path, attr string, dest []byte) (int, error) {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	a, err := syscall.BytePtrFromString(attr)
+	if err != nil {
+		return 0, err
+	}
+	n, _, errno := syscall.Syscall6(syscall.SYS_LGETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(a)), uintptr(bufPtr(dest)), uintptr(len(dest)), 0, 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+func bufPtr(b []byte) unsafe.Pointer {
+	if len(b) == 0 {
+		return nil
+	}
+	return unsafe.Pointer(&b[0])
+}
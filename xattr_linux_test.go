@@ -0,0 +1,82 @@
+package equalfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestMetadataXattr(t *testing.T) {
+	pat := "equalfiles_test_xattr"
+	contents := [][]byte{[]byte("abc"), []byte("abc")}
+	tmpFiles := makeTmpFiles(t, pat, contents)
+	defer cleanupTmpFiles(tmpFiles)
+
+	path1, path2 := tmpFiles[0].Name(), tmpFiles[1].Name()
+
+	if err := syscall.Setxattr(path1, "user.equalfile", []byte("1"), 0); err != nil {
+		t.Skipf("filesystem does not support user xattrs: %v", err)
+	}
+
+	c := New(nil, Options{Metadata: MetadataXattr})
+	if eq, err := c.CompareFile(path1, path2); eq || err != nil || c.LastResult().MetadataDiff != MetadataXattr {
+		t.Errorf("CompareFile: got %v %v diff=%v expected xattr difference", eq, err, c.LastResult().MetadataDiff)
+	}
+
+	if err := syscall.Setxattr(path2, "user.equalfile", []byte("1"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if eq, err := c.CompareFile(path1, path2); !eq || err != nil {
+		t.Errorf("CompareFile: got %v %v diff=%v expected equal", eq, err, c.LastResult().MetadataDiff)
+	}
+}
+
+func TestMetadataXattrSymlink(t *testing.T) {
+	pat := "equalfiles_test_xattr_link"
+	contents := [][]byte{[]byte("abc"), []byte("abc")}
+	tmpFiles := makeTmpFiles(t, pat, contents)
+	defer cleanupTmpFiles(tmpFiles)
+
+	path1, path2 := tmpFiles[0].Name(), tmpFiles[1].Name()
+
+	if err := syscall.Setxattr(path1, "user.equalfile", []byte("1"), 0); err != nil {
+		t.Skipf("filesystem does not support user xattrs: %v", err)
+	}
+
+	dir, errDir := ioutil.TempDir("", pat)
+	if errDir != nil {
+		t.Fatal(errDir)
+	}
+	defer os.RemoveAll(dir)
+
+	link1 := filepath.Join(dir, "link1")
+	link2 := filepath.Join(dir, "link2")
+	if err := os.Symlink(path1, link1); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(path2, link2); err != nil {
+		t.Fatal(err)
+	}
+
+	// dangling links have no target to read xattrs from
+	c := New(nil, Options{Metadata: MetadataXattr, Symlinks: SymlinkCompareTarget})
+	dangling1 := filepath.Join(dir, "dangling1")
+	dangling2 := filepath.Join(dir, "dangling2")
+	if err := os.Symlink("missing", dangling1); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("missing", dangling2); err != nil {
+		t.Fatal(err)
+	}
+	if eq, err := c.CompareFile(dangling1, dangling2); !eq || err != nil {
+		t.Errorf("CompareFile: got %v %v diff=%v expected equal dangling links", eq, err, c.LastResult().MetadataDiff)
+	}
+
+	// followed links compare the targets
+	c = New(nil, Options{Metadata: MetadataXattr})
+	if eq, err := c.CompareFile(link1, link2); eq || err != nil || c.LastResult().MetadataDiff != MetadataXattr {
+		t.Errorf("CompareFile: got %v %v diff=%v expected xattr difference", eq, err, c.LastResult().MetadataDiff)
+	}
+}
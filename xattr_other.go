@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package equalfile
+
+import "fmt"
+
+func fileXattrs(path string, follow bool) (map[string]string, error) {
+	return nil, fmt.Errorf("extended attribute comparison not supported on this platform")
+}